@@ -0,0 +1,75 @@
+package hcl
+
+import (
+	"math/big"
+
+	"github.com/alecthomas/participle"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ToCty converts a Value into a cty.Value, for use with cty-based evaluation.
+//
+// Lists convert to tuples and maps to objects, as their elements may be of differing types.
+// Null converts to a null value of dynamic type. Type keywords and expressions can't be converted.
+func (v *Value) ToCty() (cty.Value, error) {
+	switch {
+	case v.Null:
+		return cty.NullVal(cty.DynamicPseudoType), nil
+
+	case v.Bool != nil:
+		return cty.BoolVal(bool(*v.Bool)), nil
+
+	case v.Number != nil:
+		// cty takes ownership of the number, so it must not share the AST's.
+		return cty.NumberVal(new(big.Float).Copy(v.Number)), nil
+
+	case v.Str != nil:
+		return cty.StringVal(*v.Str), nil
+
+	case v.HeredocDelimiter != "":
+		return cty.StringVal(v.GetHeredoc()), nil
+
+	case v.HaveList:
+		if len(v.List) == 0 {
+			return cty.EmptyTupleVal, nil
+		}
+		elements := make([]cty.Value, 0, len(v.List))
+		for _, element := range v.List {
+			cv, err := element.ToCty()
+			if err != nil {
+				return cty.NilVal, err
+			}
+			elements = append(elements, cv)
+		}
+		return cty.TupleVal(elements), nil
+
+	case v.HaveMap:
+		if len(v.Map) == 0 {
+			return cty.EmptyObjectVal, nil
+		}
+		attrs := make(map[string]cty.Value, len(v.Map))
+		for _, entry := range v.Map {
+			var key string
+			switch {
+			case entry.Key.Str != nil:
+				key = *entry.Key.Str
+			case entry.Key.Type != nil:
+				key = *entry.Key.Type
+			default:
+				return cty.NilVal, participle.Errorf(entry.Key.Pos, "map key must be a string or type but is %s", entry.Key)
+			}
+			cv, err := entry.Value.ToCty()
+			if err != nil {
+				return cty.NilVal, err
+			}
+			attrs[key] = cv
+		}
+		return cty.ObjectVal(attrs), nil
+
+	case v.Type != nil:
+		return cty.NilVal, participle.Errorf(v.Pos, "can't convert type %s to a cty value", *v.Type)
+
+	default:
+		return cty.NilVal, participle.Errorf(v.Pos, "can't convert %s to a cty value", v)
+	}
+}
@@ -0,0 +1,65 @@
+package hcl
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestValueToCty(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected cty.Value
+		fail     string
+	}{
+		{name: "List",
+			src:      `[1, "two", true, null]`,
+			expected: cty.TupleVal([]cty.Value{cty.NumberIntVal(1), cty.StringVal("two"), cty.True, cty.NullVal(cty.DynamicPseudoType)})},
+		{name: "EmptyList", src: `[]`, expected: cty.EmptyTupleVal},
+		{name: "Map",
+			src: `{name: "web", ports: [80, 443], nested: {enabled: false}}`,
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"name":   cty.StringVal("web"),
+				"ports":  cty.TupleVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(443)}),
+				"nested": cty.ObjectVal(map[string]cty.Value{"enabled": cty.False}),
+			})},
+		{name: "EmptyMap", src: `{}`, expected: cty.EmptyObjectVal},
+		{name: "Type", src: `[string]`, fail: `1:10: can't convert type string to a cty value`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ast, err := ParseString("value = " + test.src)
+			require.NoError(t, err)
+			actual, err := ast.Entries[0].Attribute.Value.ToCty()
+			if test.fail != "" {
+				require.EqualError(t, err, test.fail)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, test.expected.RawEquals(actual), "%#v != %#v", test.expected, actual)
+		})
+	}
+}
+
+func TestValueToCtyPrecision(t *testing.T) {
+	ast, err := ParseString("value = 123456789012345678901234567890")
+	require.NoError(t, err)
+	actual, err := ast.Entries[0].Attribute.Value.ToCty()
+	require.NoError(t, err)
+	expected, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	n, _ := actual.AsBigFloat().Int(nil)
+	require.Equal(t, expected, n)
+}
+
+func TestValueToCtyDoesNotShareNumber(t *testing.T) {
+	ast, err := ParseString("value = 42")
+	require.NoError(t, err)
+	value := ast.Entries[0].Attribute.Value
+	actual, err := value.ToCty()
+	require.NoError(t, err)
+	value.Number.SetInt64(7)
+	require.True(t, actual.Equals(cty.NumberIntVal(42)).True())
+}
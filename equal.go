@@ -0,0 +1,114 @@
+package hcl
+
+// Equal returns true if the two ASTs are semantically equivalent.
+//
+// Comments, positions and formatting are ignored, as is the order of keys within map values.
+// Numbers are compared by value, and heredocs are compared with strings by their content.
+// The order of entries is significant.
+func (a *AST) Equal(b *AST) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Schema == b.Schema && equalEntries(a.Entries, b.Entries)
+}
+
+func equalEntries(a, b []*Entry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalEntry(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalEntry(a, b *Entry) bool {
+	switch {
+	case a.Attribute != nil && b.Attribute != nil:
+		return a.Attribute.Key == b.Attribute.Key &&
+			a.Attribute.Optional == b.Attribute.Optional &&
+			a.Attribute.Commented == b.Attribute.Commented &&
+			equalValues(a.Attribute.Value, b.Attribute.Value)
+
+	case a.Block != nil && b.Block != nil:
+		return a.Block.Name == b.Block.Name &&
+			a.Block.Repeated == b.Block.Repeated &&
+			equalStrings(a.Block.Labels, b.Block.Labels) &&
+			equalEntries(a.Block.Body, b.Block.Body)
+
+	default:
+		return false
+	}
+}
+
+func equalValues(a, b *Value) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	// Heredocs and strings are interchangeable.
+	if as, ok := stringContent(a); ok {
+		bs, ok := stringContent(b)
+		return ok && as == bs
+	}
+	switch {
+	case a.Bool != nil:
+		return b.Bool != nil && *a.Bool == *b.Bool
+
+	case a.Null:
+		return b.Null
+
+	case a.Number != nil:
+		return b.Number != nil && a.Number.Cmp(b.Number) == 0
+
+	case a.Type != nil:
+		return b.Type != nil && *a.Type == *b.Type
+
+	case a.Expr != nil:
+		return b.Expr != nil && *a.Expr == *b.Expr
+
+	case a.HaveList:
+		if !b.HaveList || len(a.List) != len(b.List) {
+			return false
+		}
+		for i := range a.List {
+			if !equalValues(a.List[i], b.List[i]) {
+				return false
+			}
+		}
+		return true
+
+	case a.HaveMap:
+		if !b.HaveMap || len(a.Map) != len(b.Map) {
+			return false
+		}
+		for _, ae := range a.Map {
+			found := false
+			for _, be := range b.Map {
+				if equalValues(ae.Key, be.Key) {
+					found = equalValues(ae.Value, be.Value)
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return false
+	}
+}
+
+func stringContent(v *Value) (string, bool) {
+	switch {
+	case v.Str != nil:
+		return *v.Str, true
+	case v.HeredocDelimiter != "":
+		return v.GetHeredoc(), true
+	default:
+		return "", false
+	}
+}
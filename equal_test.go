@@ -0,0 +1,52 @@
+package hcl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestASTEqual(t *testing.T) {
+	tests := []struct {
+		name  string
+		a     string
+		b     string
+		equal bool
+	}{
+		{name: "Formatting",
+			a: `
+// A comment.
+name = "web"
+service "http" {
+  port = 80
+  tags = ["a", "b"]
+}
+`,
+			b: `name="web"
+service "http" { port = 80.0
+  tags = [
+    "a",
+    "b",
+  ] // Trailing.
+}`,
+			equal: true},
+		{name: "MapKeyOrder", a: `m = {a: 1, "b": 2}`, b: `m = {b = 2, a = 1}`, equal: true},
+		{name: "Heredoc", a: "s = <<EOF\nhello\nEOF\n", b: `s = "hello"`, equal: true},
+		{name: "NumberValue", a: `n = 1000`, b: `n = 1e3`, equal: true},
+		{name: "DifferentValue", a: `n = 1`, b: `n = 2`},
+		{name: "DifferentLabel", a: `b "x" {}`, b: `b "y" {}`},
+		{name: "DifferentOrder", a: "a = 1\nb = 2", b: "b = 2\na = 1"},
+		{name: "BlockVersusAttribute", a: `a {}`, b: `a = {}`},
+		{name: "StringVersusNumber", a: `a = "1"`, b: `a = 1`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, err := ParseString(test.a)
+			require.NoError(t, err)
+			b, err := ParseString(test.b)
+			require.NoError(t, err)
+			require.Equal(t, test.equal, a.Equal(b))
+			require.Equal(t, test.equal, b.Equal(a))
+		})
+	}
+}
@@ -6,4 +6,5 @@ require (
 	github.com/alecthomas/participle v0.6.1-0.20200911005820-318127ca69ac
 	github.com/alecthomas/repr v0.0.0-20200325044227-4184120f674c
 	github.com/stretchr/testify v1.4.0
+	github.com/zclconf/go-cty v1.8.0
 )
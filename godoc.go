@@ -0,0 +1,73 @@
+package hcl
+
+import (
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+)
+
+// WithGoDoc attaches the Go doc comments of the fields of struct type "typeName", declared in
+// the Go source file "filename", as comments when marshalling.
+//
+// Comments from a "help" tag take precedence. The file is parsed when WithGoDoc is called, so
+// the source must be available at that point, eg. from a code generator.
+func WithGoDoc(filename string, typeName string) MarshalOption {
+	name, docs, err := parseGoDoc(filename, typeName)
+	return func(options *marshalOptions) {
+		if err != nil {
+			options.goDocErr = err
+			return
+		}
+		if options.goDocs == nil {
+			options.goDocs = map[string]map[string]string{}
+		}
+		options.goDocs[name] = docs
+	}
+}
+
+// goDoc returns the doc comment of field "fieldName" of struct type "t", if any.
+//
+// Types are keyed by their package qualified name, so that types of the same name in different
+// packages are kept apart.
+func (o *marshalOptions) goDoc(t reflect.Type, fieldName string) string {
+	return o.goDocs[t.String()][fieldName]
+}
+
+// parseGoDoc extracts the doc comments of each field of a struct type from a Go source file,
+// along with the package qualified name of the type.
+func parseGoDoc(filename string, typeName string) (string, map[string]string, error) {
+	file, err := goparser.ParseFile(token.NewFileSet(), filename, nil, goparser.ParseComments)
+	if err != nil {
+		return "", nil, err
+	}
+	var st *ast.StructType
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.TypeSpec)
+		if !ok || spec.Name.Name != typeName {
+			return st == nil
+		}
+		st, _ = spec.Type.(*ast.StructType)
+		return false
+	})
+	if st == nil {
+		return "", nil, fmt.Errorf("%s: no struct type %q found", filename, typeName)
+	}
+	docs := map[string]string{}
+	for _, field := range st.Fields.List {
+		doc := field.Doc
+		if doc == nil {
+			doc = field.Comment
+		}
+		if doc == nil {
+			continue
+		}
+		text := strings.TrimSpace(doc.Text())
+		for _, name := range field.Names {
+			docs[name.Name] = text
+		}
+	}
+	return file.Name.Name + "." + typeName, docs, nil
+}
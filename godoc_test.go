@@ -0,0 +1,65 @@
+package hcl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type goDocConfig struct {
+	// Name of the service.
+	Name string `hcl:"name"`
+	Port int    `hcl:"port"` // Port to listen on.
+	// Ignored in favour of the help tag.
+	Debug bool     `hcl:"debug" help:"Enable debugging."`
+	Tags  []string `hcl:"tags"`
+}
+
+func TestWithGoDoc(t *testing.T) {
+	data, err := Marshal(&goDocConfig{Name: "web", Port: 80}, WithGoDoc("godoc_test.go", "goDocConfig"))
+	require.NoError(t, err)
+	require.Equal(t, `// Name of the service.
+name = "web"
+// Port to listen on.
+port = 80
+// Enable debugging.
+debug = false
+tags = []
+`, string(data))
+
+	_, err = Marshal(&goDocConfig{}, WithGoDoc("godoc_test.go", "missing"))
+	require.EqualError(t, err, `godoc_test.go: no struct type "missing" found`)
+}
+
+func TestWithGoDocOtherPackage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hcl-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "other.go")
+	err = ioutil.WriteFile(filename, []byte(`package other
+
+type goDocConfig struct {
+	// Name of another service.
+	Name string
+}
+`), 0600)
+	require.NoError(t, err)
+	option := WithGoDoc(filename, "goDocConfig")
+
+	// The file is parsed once, when the option is created.
+	err = os.Remove(filename)
+	require.NoError(t, err)
+
+	// Docs apply only to the type of the same name in the same package.
+	data, err := Marshal(&goDocConfig{Name: "web"}, option)
+	require.NoError(t, err)
+	require.Equal(t, `name = "web"
+port = 0
+// Enable debugging.
+debug = false
+tags = []
+`, string(data))
+}
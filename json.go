@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
 
 	"github.com/alecthomas/participle/lexer"
 	"github.com/alecthomas/repr"
@@ -104,8 +106,11 @@ func (w *jsonVisitor) writeValue(node *Value) error {
 	case node.Bool != nil:
 		fmt.Fprintf(w, "%v", *node.Bool)
 
+	case node.Null:
+		fmt.Fprint(w, "null")
+
 	case node.Number != nil:
-		fmt.Fprint(w, node.Number.String())
+		fmt.Fprint(w, formatNumber(node.Number))
 
 	case node.Str != nil:
 		fmt.Fprintf(w, "%q", *node.Str)
@@ -141,8 +146,143 @@ func (w *jsonVisitor) writeValue(node *Value) error {
 	case node.Type != nil:
 		fmt.Fprintf(w, "%q", *node.Type)
 
+	case node.Expr != nil:
+		fmt.Fprintf(w, "%q", *node.Expr)
+
 	default:
 		panic(repr.String(node, repr.Hide(lexer.Position{})))
 	}
 	return nil
 }
+
+// FromJSONOptions controls how JSON documents are converted to HCL.
+type FromJSONOptions struct {
+	// Convert nested JSON objects to map attributes rather than blocks.
+	ObjectsAsMaps bool
+}
+
+// FromJSON converts a JSON document into an equivalent HCL AST.
+//
+// The document must be a JSON object. Its keys are converted to entries in source order
+// using the following rules:
+//
+// - objects become blocks (or map attributes if FromJSONOptions.ObjectsAsMaps is set)
+// - non-empty arrays consisting solely of objects become repeated blocks
+// - everything else becomes an attribute
+//
+// Objects nested inside attribute values become HCL maps, and keys that are not valid
+// identifiers are always converted to attributes.
+func FromJSON(data []byte) (*AST, error) {
+	return FromJSONWithOptions(data, FromJSONOptions{})
+}
+
+// FromJSONWithOptions converts a JSON document into an equivalent HCL AST.
+//
+// See FromJSON for details.
+func FromJSONWithOptions(data []byte, options FromJSONOptions) (*AST, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected trailing data after JSON document")
+	}
+	if !value.HaveMap {
+		return nil, fmt.Errorf("expected a JSON object at the top level but got %s", value)
+	}
+	return &AST{Entries: jsonMapToEntries(value.Map, options)}, nil
+}
+
+func jsonMapToEntries(entries []*MapEntry, options FromJSONOptions) []*Entry {
+	out := []*Entry{}
+	for _, entry := range entries {
+		key := *entry.Key.Str
+		value := entry.Value
+		blocks := !options.ObjectsAsMaps && identifierRe.MatchString(key)
+		switch {
+		case blocks && value.HaveMap:
+			out = append(out, &Entry{Block: &Block{Name: key, Body: jsonMapToEntries(value.Map, options)}})
+
+		case blocks && isJSONObjectList(value):
+			for _, el := range value.List {
+				out = append(out, &Entry{Block: &Block{Name: key, Body: jsonMapToEntries(el.Map, options)}})
+			}
+
+		default:
+			out = append(out, &Entry{Attribute: &Attribute{Key: key, Value: value}})
+		}
+	}
+	return out
+}
+
+func isJSONObjectList(value *Value) bool {
+	if !value.HaveList || len(value.List) == 0 {
+		return false
+	}
+	for _, el := range value.List {
+		if !el.HaveMap {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeJSONValue decodes a single JSON value from the token stream, preserving object key order.
+func decodeJSONValue(dec *json.Decoder) (*Value, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token := token.(type) {
+	case json.Delim:
+		switch token {
+		case '{':
+			out := &Value{HaveMap: true}
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				keyStr := key.(string)
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				out.Map = append(out.Map, &MapEntry{Key: &Value{Str: &keyStr}, Value: value})
+			}
+			_, err = dec.Token()
+			return out, err
+
+		case '[':
+			out := &Value{HaveList: true}
+			for dec.More() {
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				out.List = append(out.List, value)
+			}
+			_, err = dec.Token()
+			return out, err
+		}
+
+	case json.Number:
+		n, _, err := big.ParseFloat(token.String(), 10, 256, big.ToNearestEven)
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Number: n}, nil
+
+	case string:
+		return &Value{Str: &token}, nil
+
+	case bool:
+		return &Value{Bool: (*Bool)(&token)}, nil
+
+	case nil:
+		return &Value{Null: true}, nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", token)
+}
@@ -107,3 +107,64 @@ func TestMarshalJSON(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, expected, buf.String())
 }
+
+func TestFromJSON(t *testing.T) {
+	src := `{
+  "name": "web",
+  "port": 8080,
+  "ratio": 0.5,
+  "enabled": true,
+  "parent": null,
+  "tags": ["a", "b"],
+  "labels": {"tier": "frontend"},
+  "service": [
+    {"name": "http", "env": {"DEBUG": "1"}},
+    {"name": "grpc", "hosts": [{"host": "a"}]}
+  ],
+  "not-valid key": {"a": 1}
+}`
+	ast, err := FromJSON([]byte(src))
+	require.NoError(t, err)
+	data, err := MarshalAST(ast)
+	require.NoError(t, err)
+	require.Equal(t, `name = "web"
+port = 8080
+ratio = 0.5
+enabled = true
+parent = null
+tags = ["a", "b"]
+
+labels {
+  tier = "frontend"
+}
+
+service {
+  name = "http"
+
+  env {
+    DEBUG = "1"
+  }
+}
+
+service {
+  name = "grpc"
+
+  hosts {
+    host = "a"
+  }
+}
+
+"not-valid key" = {
+  "a": 1,
+}
+`, string(data))
+
+	ast, err = FromJSONWithOptions([]byte(`{"labels": {"tier": "frontend"}}`), FromJSONOptions{ObjectsAsMaps: true})
+	require.NoError(t, err)
+	data, err = MarshalAST(ast)
+	require.NoError(t, err)
+	require.Equal(t, "labels = {\n  \"tier\": \"frontend\",\n}\n", string(data))
+
+	_, err = FromJSON([]byte(`[1, 2]`))
+	require.EqualError(t, err, "expected a JSON object at the top level but got [1, 2]")
+}
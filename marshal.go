@@ -3,19 +3,84 @@ package hcl
 import (
 	"bytes"
 	"encoding"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"math/big"
 	"reflect"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// EntriesMarshaler is implemented by types that can convert themselves into HCL entries without reflection.
+//
+// This is intended for performance-critical types, typically with generated implementations. The
+// entries are used as the body of the document or block the value is marshalled into. It is not used
+// when generating schemas.
+type EntriesMarshaler interface {
+	MarshalHCLEntries() ([]*Entry, error)
+}
+
+var entriesMarshalerInterface = reflect.TypeOf((*EntriesMarshaler)(nil)).Elem()
+
 // marshalOptions defines options for the marshalling/unmarshalling process
 type marshalOptions struct {
-	inferHCLTags bool
+	inferHCLTags    bool
+	lineEnding      string
+	skipUnsupported bool
+	appendBlocks    bool
+	strictKeys      bool
+	decodeHooks     []DecodeHook
+
+	requireAllNonOptional bool
+	timeLayouts           []string
+	wrapListsOver         int
+	quoteAll              bool
+	mapSeparator          string
+	commentWidth          int
+	digitGrouping         bool
+	sortLists             bool
+	keyCase               KeyCase
+	compactEmptyBlocks    bool
+	compact               bool
+	header                string
+	asciiOnly             bool
+	indexComments         bool
+	valueFormatters       map[reflect.Type]func(reflect.Value) (string, error)
+	baseline              *AST
+	// Previous values of changed attributes, from the baseline.
+	changes             map[*Attribute]string
+	attributeOrder      map[string]int
+	mapKeyOrder         map[string][]string
+	goDocs              map[string]map[string]string
+	goDocErr            error
+	maxDepth            int
+	schemaSamples       bool
+	commentOptionals    bool
+	schemaDefaults      bool
+	schemaValidationTag string
+
+	accumulateRepeatedAttributes bool
+	trailingNewline              *bool
+	unquotedLabels               bool
+	interfaceDefaults            map[reflect.Type]reflect.Type
+	maxLineLength                int
+	allowColonAssignment         bool
+	warnings                     *[]Warning
+	marshalStringers             bool
+	reuseSlices                  bool
+
+	// Format integers directly into Value.Raw rather than via big.Float, see forText.
+	rawIntegers bool
+
+	// Traversal state: depth is tracked when maxDepth is set, and path when either maxDepth or
+	// mapKeyOrder is set.
+	depth int
+	path  string
 }
 
 // MarshalOption configures optional marshalling behaviour.
@@ -30,6 +95,411 @@ func InferHCLTags(v bool) MarshalOption {
 	}
 }
 
+// LineEnding specifies the line ending used when writing HCL, eg. "\r\n" for Windows line endings.
+//
+// Defaults to "\n".
+func LineEnding(eol string) MarshalOption {
+	return func(options *marshalOptions) {
+		options.lineEnding = eol
+	}
+}
+
+// SkipUnsupported specifies whether fields of types that can't be represented in HCL, such as
+// functions and channels, should be silently omitted rather than returning an error.
+func SkipUnsupported(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.skipUnsupported = v
+	}
+}
+
+// StrictKeys specifies that attribute keys which are not valid identifiers, such as "log.level",
+// should result in an error rather than being quoted.
+func StrictKeys(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.strictKeys = v
+	}
+}
+
+// DecodeHook is called before decoding each value.
+//
+// "from" is the Go type the Value would naturally decode to, and "to" is the type of the destination.
+//
+// If the hook returns nil, decoding continues as normal. If it returns a *Value, that value is
+// passed to subsequent hooks and decoded in place of the original. Any other value is assigned to
+// the destination, converting it if necessary, and decoding of that value stops.
+type DecodeHook func(from reflect.Type, to reflect.Type, data *Value) (interface{}, error)
+
+// WithDecodeHook adds a hook that is called before decoding each value.
+//
+// Multiple hooks are applied in the order they are added.
+func WithDecodeHook(hook DecodeHook) MarshalOption {
+	return func(options *marshalOptions) {
+		options.decodeHooks = append(options.decodeHooks, hook)
+	}
+}
+
+// RequireAllNonOptional requires every non-pointer field not explicitly tagged "optional" to be present
+// when unmarshalling.
+//
+// By default, blocks and fields without an hcl tag are treated as optional.
+func RequireAllNonOptional() MarshalOption {
+	return func(options *marshalOptions) {
+		options.requireAllNonOptional = true
+	}
+}
+
+// TimeLayouts specifies the layouts accepted when unmarshalling time.Time values.
+//
+// Layouts are tried in order and the first successful parse is used. Defaults to time.RFC3339.
+// Times are always marshalled as RFC3339.
+func TimeLayouts(layouts []string) MarshalOption {
+	return func(options *marshalOptions) {
+		options.timeLayouts = layouts
+	}
+}
+
+// WrapListsOver renders lists with more than n elements over multiple lines, one element per line.
+//
+// By default lists are always rendered on a single line.
+func WrapListsOver(n int) MarshalOption {
+	return func(options *marshalOptions) {
+		options.wrapListsOver = n
+	}
+}
+
+// MaxLineLength wraps lists that would otherwise make a line longer than n characters.
+//
+// Wrapped lists are rendered one element per line, and each element is then considered in turn,
+// so nested lists are only wrapped as far as necessary. Maps are always rendered over multiple
+// lines. Single tokens such as strings, numbers and heredocs are never split, so a line may still
+// exceed the limit if it contains a long token. Comments are wrapped separately by CommentWidth.
+func MaxLineLength(n int) MarshalOption {
+	return func(options *marshalOptions) {
+		options.maxLineLength = n
+	}
+}
+
+// QuoteAll marshals numbers and booleans as quoted strings, eg. port = "8080".
+//
+// When unmarshalling, quoted strings are accepted for number and bool fields.
+func QuoteAll(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.quoteAll = v
+	}
+}
+
+// MapSeparator specifies the separator between keys and values in map literals, either ":" (the default) or "=".
+//
+// Both separators are accepted when parsing.
+func MapSeparator(sep string) MarshalOption {
+	return func(options *marshalOptions) {
+		options.mapSeparator = sep
+	}
+}
+
+// CommentWidth re-flows comments to fit within the given number of columns, including
+// indentation and the "// " prefix.
+//
+// Explicit newlines in comments are preserved. Words longer than the width are not broken.
+// A width of 0 (the default) disables wrapping.
+func CommentWidth(width int) MarshalOption {
+	return func(options *marshalOptions) {
+		options.commentWidth = width
+	}
+}
+
+// DigitGrouping inserts underscores between every three digits of integer literals, eg. 1_000_000.
+func DigitGrouping(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.digitGrouping = v
+	}
+}
+
+// SortLists sorts the elements of list attributes by their rendered form, for deterministic
+// output of lists where order is not significant, such as sets of tags.
+func SortLists(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.sortLists = v
+	}
+}
+
+// KeyCase controls how keys are derived from Go field names when a field has no explicit name.
+type KeyCase int
+
+// Key cases.
+const (
+	// KeyCaseExact uses the Go field name as-is, eg. MaxRetries. This is the default.
+	KeyCaseExact KeyCase = iota
+	// KeyCaseLower lowercases the Go field name, eg. maxretries.
+	KeyCaseLower
+	// KeyCaseSnake converts the Go field name to snake case, eg. max_retries.
+	KeyCaseSnake
+)
+
+// UntaggedKeyCase specifies how keys are derived from the names of fields that are untagged, or
+// whose tag does not include a name.
+func UntaggedKeyCase(c KeyCase) MarshalOption {
+	return func(options *marshalOptions) {
+		options.keyCase = c
+	}
+}
+
+// CompactEmptyBlocks renders blocks with no body as "name {}" on a single line, rather than
+// with the closing brace on its own line.
+func CompactEmptyBlocks(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.compactEmptyBlocks = v
+	}
+}
+
+// Compact marshals the whole document on a single line, eg. `a = 1 b "x" { c = [1, 2] }`.
+//
+// Entries are separated by a single space, blocks enclose their body in "{ " and " }" (or "{}" if
+// empty), and lists and maps are always written inline. Heredocs are written as quoted strings, and
+// comments and the schema "(optional)" and "(repeated)" annotations are omitted. Expressions are
+// written verbatim. No trailing newline is written unless requested with TrailingNewline(true).
+func Compact(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.compact = v
+	}
+}
+
+// ASCIIOnly escapes non-ASCII characters in strings, labels and quoted keys as \u or \U sequences.
+//
+// Heredocs are written as quoted strings so that they can be escaped. Comments are written as-is.
+func ASCIIOnly(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.asciiOnly = v
+	}
+}
+
+// WithValueFormatter marshals values of type t as the HCL literal returned by format, written verbatim.
+//
+// eg. a formatter returning fmt.Sprintf("%.2f", v.Float()) always writes two decimal places. The
+// literal must be a valid HCL value, and is decoded as usual when unmarshalling.
+func WithValueFormatter(t reflect.Type, format func(reflect.Value) (string, error)) MarshalOption {
+	return func(options *marshalOptions) {
+		if options.valueFormatters == nil {
+			options.valueFormatters = map[reflect.Type]func(reflect.Value) (string, error){}
+		}
+		options.valueFormatters[t] = format
+	}
+}
+
+// IndexComments precedes each block marshalled from a slice with a comment containing its index, eg. "// [0]".
+func IndexComments(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.indexComments = v
+	}
+}
+
+// WithHeader writes a comment above the whole document, eg. "Code generated by tool. DO NOT EDIT.".
+//
+// The header may span multiple lines, and precedes any LeadingComments of the AST.
+func WithHeader(header string) MarshalOption {
+	return func(options *marshalOptions) {
+		options.header = header
+	}
+}
+
+// AnnotateChanges marks attributes whose value differs from the same attribute in baseline with
+// a trailing comment containing the previous value, eg. "port = 80 // was 8080".
+//
+// Blocks are matched by name and labels, in order. Attributes absent from the baseline are not
+// annotated. This only applies when marshalling a whole document.
+func AnnotateChanges(baseline *AST) MarshalOption {
+	return func(options *marshalOptions) {
+		options.baseline = baseline
+	}
+}
+
+// UnquotedLabels emits block labels that are valid identifiers without quotes, eg. `variable foo {}`.
+//
+// Labels that are not identifiers are always quoted.
+func UnquotedLabels(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.unquotedLabels = v
+	}
+}
+
+// AllowColonAssignment accepts "key: value" as well as "key = value" for attributes when unmarshalling.
+//
+// Marshalling always emits "=".
+func AllowColonAssignment() MarshalOption {
+	return func(options *marshalOptions) {
+		options.allowColonAssignment = true
+	}
+}
+
+// WithWarnings appends a Warning to "warnings" for each attribute or block preceded by a comment
+// starting with "DEPRECATED" when unmarshalling, eg. "// DEPRECATED: use timeout instead".
+func WithWarnings(warnings *[]Warning) MarshalOption {
+	return func(options *marshalOptions) {
+		options.warnings = warnings
+	}
+}
+
+// MarshalStringers marshals values implementing error or fmt.Stringer, such as error fields, as
+// strings using their Error() or String() methods.
+//
+// This only applies to values that are not otherwise handled specially, eg. by implementing
+// encoding.TextMarshaler. It is one-way: such strings generally can't be unmarshalled back.
+func MarshalStringers(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.marshalStringers = v
+	}
+}
+
+// ReuseSlices decodes into the existing elements of non-nil slices when unmarshalling, rather than
+// allocating new slices, eg. to reduce allocations when repeatedly decoding into pooled values.
+//
+// Slices are always truncated before decoding, so elements are never appended to existing ones.
+// However, as reused elements are decoded into in place, fields absent from the input retain their
+// previous values.
+func ReuseSlices(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.reuseSlices = v
+	}
+}
+
+// AttributeOrder reorders attributes within each block to match the order of the given keys.
+//
+// Attributes with unlisted keys follow listed attributes, in their original order. Blocks are not moved.
+func AttributeOrder(keys []string) MarshalOption {
+	return func(options *marshalOptions) {
+		options.attributeOrder = map[string]int{}
+		for i, key := range keys {
+			if _, ok := options.attributeOrder[key]; !ok {
+				options.attributeOrder[key] = i
+			}
+		}
+	}
+}
+
+// MapKeyOrder orders the keys of marshalled maps, keyed by the path of the map field.
+//
+// Paths are the dotted HCL names of the enclosing fields, with list indices in brackets, eg.
+// "service[0].tags". Keys listed for a path come first, in the given order, followed by the
+// remaining keys sorted alphabetically as usual.
+func MapKeyOrder(order map[string][]string) MarshalOption {
+	return func(options *marshalOptions) {
+		options.mapKeyOrder = order
+	}
+}
+
+// MaxDepth limits the nesting depth of structs when marshalling, with the top-level struct at depth 1.
+//
+// Exceeding the limit, eg. due to a cyclic structure, is an error. 0 (the default) is unlimited.
+func MaxDepth(depth int) MarshalOption {
+	return func(options *marshalOptions) {
+		options.maxDepth = depth
+	}
+}
+
+// SchemaSamples emits sample values such as "..." or 0 as placeholders in schemas, rather than
+// type keywords such as string or number, so that the schema can double as a starter configuration.
+func SchemaSamples(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.schemaSamples = v
+	}
+}
+
+// SchemaDefaults emits the value of a field's `default:""` tag as its placeholder in schemas.
+//
+// Defaults are HCL literals, eg. `default:"8080"` or `default:"[\"a\"]"`, except for string
+// fields where the tag value is the string itself, eg. `default:"localhost"`.
+func SchemaDefaults(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.schemaDefaults = v
+	}
+}
+
+// CommentOptionalDefaults writes optional attributes in schemas commented out with their default or
+// zero value, eg. "// port = 8080", so that they can be uncommented to set them.
+//
+// Defaults are read from the field's `default:""` tag. Commented attributes are omitted from compact output.
+func CommentOptionalDefaults(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.commentOptionals = v
+	}
+}
+
+// SchemaValidationTag documents validation constraints in schemas, read from the given struct tag.
+//
+// The constraints are added to the field's comments with commas replaced by spaces, eg. a field
+// tagged with `validate:"min=1,max=65535"` is commented "// min=1 max=65535" for SchemaValidationTag("validate").
+func SchemaValidationTag(name string) MarshalOption {
+	return func(options *marshalOptions) {
+		options.schemaValidationTag = name
+	}
+}
+
+// AccumulateRepeatedAttributes allows attributes to be repeated when unmarshalling into a slice,
+// with the values of each accumulated into the slice, eg. `tag = "a"` `tag = "b"` → []string{"a", "b"}.
+//
+// Values that are themselves lists are concatenated. Without this option, repeated attributes are an error.
+func AccumulateRepeatedAttributes() MarshalOption {
+	return func(options *marshalOptions) {
+		options.accumulateRepeatedAttributes = true
+	}
+}
+
+// TrailingNewline controls whether marshalled output ends with a newline.
+//
+// By default, output for an AST, block or attribute ends with a single newline, while output for a
+// bare value does not. If true, non-empty output always ends with a newline, and if false the final
+// newline is omitted.
+func TrailingNewline(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.trailingNewline = &v
+	}
+}
+
+// WithInterfaceDefault decodes into fields of the interface type "iface" using the concrete type of "prototype".
+//
+// "prototype" is typically a nil pointer or zero value, eg. (*Plugin)(nil). Without a default, fields
+// of type interface{} decode into the natural Go type of the value, with blocks decoding into
+// map[string]interface{}, while fields of other interface types are an error.
+func WithInterfaceDefault(iface reflect.Type, prototype interface{}) MarshalOption {
+	concrete := reflect.TypeOf(prototype)
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("%s is not an interface", iface))
+	}
+	if concrete == nil || !concrete.Implements(iface) {
+		panic(fmt.Sprintf("%v does not implement %s", concrete, iface))
+	}
+	return func(options *marshalOptions) {
+		if options.interfaceDefaults == nil {
+			options.interfaceDefaults = map[reflect.Type]reflect.Type{}
+		}
+		options.interfaceDefaults[iface] = concrete
+	}
+}
+
+// descend returns options for marshalling the child element "elem" of the current value.
+//
+// Element names starting with "[" are indices, others are keys.
+func (o *marshalOptions) descend(elem string) *marshalOptions {
+	if o.maxDepth == 0 && o.mapKeyOrder == nil {
+		return o
+	}
+	child := *o
+	if o.path != "" && !strings.HasPrefix(elem, "[") {
+		child.path += "."
+	}
+	child.path += elem
+	return &child
+}
+
+// descendIndex is descend for the i'th element of a list, avoiding formatting the index when the
+// path isn't tracked.
+func (o *marshalOptions) descendIndex(i int) *marshalOptions {
+	if o.maxDepth == 0 && o.mapKeyOrder == nil {
+		return o
+	}
+	return o.descend("[" + strconv.Itoa(i) + "]")
+}
+
 // newMarshalOptions creates marshal options from a set of options
 func newMarshalOptions(options ...MarshalOption) *marshalOptions {
 	opt := &marshalOptions{}
@@ -39,17 +509,233 @@ func newMarshalOptions(options ...MarshalOption) *marshalOptions {
 	return opt
 }
 
+// Options is a reusable, pre-built set of MarshalOptions.
+//
+// An Options value is immutable once constructed, so it can be built once and shared by
+// any number of concurrent MarshalWith calls. Maps and ASTs passed to options are copied, so
+// later changes to them by the caller have no effect. The slice passed to WithWarnings is the
+// exception, as it is written to by each call.
+type Options struct {
+	opt *marshalOptions
+}
+
+// NewOptions applies the given options once, for reuse across many MarshalWith calls.
+func NewOptions(options ...MarshalOption) *Options {
+	opt := newMarshalOptions(options...)
+	// Detach from any slice or map the option functions may share with other option sets.
+	opt.decodeHooks = append([]DecodeHook(nil), opt.decodeHooks...)
+	opt.timeLayouts = append([]string(nil), opt.timeLayouts...)
+	if opt.interfaceDefaults != nil {
+		interfaceDefaults := make(map[reflect.Type]reflect.Type, len(opt.interfaceDefaults))
+		for iface, concrete := range opt.interfaceDefaults {
+			interfaceDefaults[iface] = concrete
+		}
+		opt.interfaceDefaults = interfaceDefaults
+	}
+	if opt.mapKeyOrder != nil {
+		mapKeyOrder := make(map[string][]string, len(opt.mapKeyOrder))
+		for path, keys := range opt.mapKeyOrder {
+			mapKeyOrder[path] = append([]string(nil), keys...)
+		}
+		opt.mapKeyOrder = mapKeyOrder
+	}
+	if opt.valueFormatters != nil {
+		valueFormatters := make(map[reflect.Type]func(reflect.Value) (string, error), len(opt.valueFormatters))
+		for t, format := range opt.valueFormatters {
+			valueFormatters[t] = format
+		}
+		opt.valueFormatters = valueFormatters
+	}
+	opt.baseline = opt.baseline.Clone()
+	return &Options{opt: opt}
+}
+
+// forText returns options for marshalling to text, rather than to an AST returned to the caller.
+//
+// Integers are then formatted directly, avoiding a big.Float allocation for each one, unless
+// digit grouping or change annotation needs their numeric value.
+func (o *marshalOptions) forText() *marshalOptions {
+	out := *o
+	out.rawIntegers = !o.digitGrouping && o.baseline == nil
+	return &out
+}
+
 // Marshal a Go type to HCL.
 func Marshal(v interface{}, options ...MarshalOption) ([]byte, error) {
-	opt := &marshalOptions{}
-	for _, option := range options {
-		option(opt)
+	return marshal(v, newMarshalOptions(options...))
+}
+
+// MarshalWith marshals a Go type to HCL using a pre-built set of Options.
+func MarshalWith(opts *Options, v interface{}) ([]byte, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	return marshal(v, opts.opt)
+}
+
+// MarshalToWriter marshals a Go type to HCL, writing directly to w rather than buffering the output.
+//
+// If marshalling fails part way through, w may have received partial output.
+func MarshalToWriter(w io.Writer, v interface{}, options ...MarshalOption) error {
+	opt := newMarshalOptions(options...).forText()
+	ast, err := marshalToAST(v, false, opt)
+	if err != nil {
+		return err
+	}
+	return marshalASTToWriter(ast, w, opt)
+}
+
+// CanMarshal checks that the type of v can be marshalled, without marshalling it.
+//
+// The whole type is checked, rather than the value, so fields that would only fail when set, such
+// as non-nil pointers or non-empty slices of unsupported types, are also reported. All invalid
+// tags and fields of unsupported types are listed in the returned error. The dynamic types of
+// interface values are not known, so are not checked.
+func CanMarshal(v interface{}, options ...MarshalOption) error {
+	opt := newMarshalOptions(options...)
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return fmt.Errorf("can't marshal nil")
+	}
+	problems := []string{}
+	checkMarshalType(t, t.String(), opt, map[reflect.Type]bool{}, &problems)
+	if len(problems) > 0 {
+		return fmt.Errorf("can't marshal %s: %s", t, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkMarshalType appends a description of each part of type t that can't be marshalled to problems.
+func checkMarshalType(t reflect.Type, path string, opt *marshalOptions, seen map[reflect.Type]bool, problems *[]string) {
+	if _, ok := opt.valueFormatters[t]; ok {
+		return
+	}
+	if registered, ok := typeRegistry[t]; ok && registered.marshal != nil {
+		return
+	}
+	switch {
+	case t == expressionType, t == jsonNumberType, t == bigFloatType, t == durationType, t == timeType,
+		typeImplements(t, textMarshalerInterface), typeImplements(t, jsonMarshalerInterface),
+		typeImplements(t, binaryMarshalerInterface),
+		opt.marshalStringers && (typeImplements(t, errorInterface) || typeImplements(t, stringerInterface)):
+		return
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		checkMarshalType(t.Elem(), path, opt, seen, problems)
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			*problems = append(*problems, fmt.Sprintf("%s: map keys must be strings but are %s", path, t.Key()))
+			return
+		}
+		checkMarshalType(t.Elem(), path, opt, seen, problems)
+
+	case reflect.Struct:
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		v := reflect.New(t).Elem()
+		fields, err := flattenFields(v)
+		if err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: %s", path, err))
+			return
+		}
+		for _, field := range fields {
+			tag, err := parseTag(t, field, opt)
+			if err != nil {
+				*problems = append(*problems, err.Error())
+				continue
+			}
+			if tag.name == "" || tag.remain || tag.commentsField {
+				continue
+			}
+			checkMarshalType(field.t.Type, path+"."+field.t.Name, opt, seen, problems)
+		}
+
+	default:
+		if !opt.skipUnsupported {
+			*problems = append(*problems, fmt.Sprintf("%s: unsupported type %s", path, t))
+		}
+	}
+}
+
+func marshal(v interface{}, opt *marshalOptions) ([]byte, error) {
+	opt = opt.forText()
+	ast, err := marshalToAST(v, false, opt)
+	if err != nil {
+		return nil, err
 	}
+	w := &bytes.Buffer{}
+	err = marshalASTToWriter(ast, w, opt)
+	return w.Bytes(), err
+}
+
+// MarshalSize returns the number of bytes Marshal would produce, without buffering the output.
+func MarshalSize(v interface{}, options ...MarshalOption) (int, error) {
+	opt := newMarshalOptions(options...).forText()
+	ast, err := marshalToAST(v, false, opt)
+	if err != nil {
+		return 0, err
+	}
+	w := &countingWriter{}
+	err = marshalASTToWriter(ast, w, opt)
+	return w.n, err
+}
+
+// MarshalFields marshals only the given attributes and blocks of a Go type to HCL.
+//
+// Fields are selected by dotted paths of attribute and block names, as they appear in the
+// marshalled output, eg. "database.host". Selecting a block includes its entire body.
+func MarshalFields(v interface{}, fieldPaths []string, options ...MarshalOption) ([]byte, error) {
 	ast, err := MarshalToAST(v, options...)
 	if err != nil {
 		return nil, err
 	}
-	return MarshalAST(ast)
+	matched := map[string]bool{}
+	ast.Entries = filterEntries(ast.Entries, "", fieldPaths, matched)
+	for _, path := range fieldPaths {
+		if !matched[path] {
+			return nil, fmt.Errorf("no attribute or block matches %q", path)
+		}
+	}
+	return MarshalAST(ast, options...)
+}
+
+func filterEntries(entries []*Entry, prefix string, paths []string, matched map[string]bool) []*Entry {
+	out := []*Entry{}
+	for _, entry := range entries {
+		key := prefix + entry.Key()
+		selected := false
+		descend := false
+		for _, path := range paths {
+			if path == key {
+				selected = true
+				matched[path] = true
+			} else if strings.HasPrefix(path, key+".") {
+				descend = true
+			}
+		}
+		switch {
+		case selected:
+			out = append(out, entry)
+
+		case descend && entry.Block != nil:
+			body := filterEntries(entry.Block.Body, key+".", paths, matched)
+			if len(body) > 0 {
+				block := *entry.Block
+				block.Body = body
+				out = append(out, &Entry{Block: &block})
+			}
+		}
+	}
+	return out
 }
 
 // MarshalToAST marshals a Go type to a hcl.AST.
@@ -58,43 +744,258 @@ func MarshalToAST(v interface{}, options ...MarshalOption) (*AST, error) {
 }
 
 // MarshalAST marshals an AST to HCL bytes.
-func MarshalAST(ast Node) ([]byte, error) {
+func MarshalAST(ast Node, options ...MarshalOption) ([]byte, error) {
 	w := &bytes.Buffer{}
-	err := MarshalASTToWriter(ast, w)
+	err := MarshalASTToWriter(ast, w, options...)
 	return w.Bytes(), err
 }
 
 // MarshalASTToWriter marshals a hcl.AST to an io.Writer.
-func MarshalASTToWriter(ast Node, w io.Writer) error {
-	return marshalNode(w, "", ast)
+func MarshalASTToWriter(ast Node, w io.Writer, options ...MarshalOption) error {
+	return marshalASTToWriter(ast, w, newMarshalOptions(options...))
+}
+
+func marshalASTToWriter(ast Node, w io.Writer, opt *marshalOptions) error {
+	if opt.lineEnding != "" && opt.lineEnding != "\n" {
+		w = &lineEndingWriter{w: w, eol: []byte(opt.lineEnding)}
+	}
+	if opt.trailingNewline == nil {
+		return marshalNode(w, "", ast, opt)
+	}
+	tw := &trailingNewlineWriter{w: w}
+	if err := marshalNode(tw, "", ast, opt); err != nil {
+		return err
+	}
+	return tw.finish(*opt.trailingNewline)
+}
+
+// trailingNewlineWriter withholds a trailing newline until more output is written, so that
+// the final newline can be added or omitted.
+type trailingNewlineWriter struct {
+	w       io.Writer
+	wrote   bool
+	pending bool
+}
+
+func (t *trailingNewlineWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if t.pending {
+		if _, err := t.w.Write([]byte("\n")); err != nil {
+			return 0, err
+		}
+		t.pending = false
+	}
+	t.wrote = true
+	out := p
+	if p[len(p)-1] == '\n' {
+		out = p[:len(p)-1]
+		t.pending = true
+	}
+	if _, err := t.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// finish the output, with or without a final newline.
+func (t *trailingNewlineWriter) finish(newline bool) error {
+	if newline && t.wrote {
+		_, err := t.w.Write([]byte("\n"))
+		return err
+	}
+	return nil
+}
+
+// isZero returns true if v is the zero value of its type, or its IsZero() method returns true.
+func isZero(v reflect.Value) bool {
+	if isNilValue(v) {
+		return true
+	}
+	if uv, ok := implements(v, isZeroerInterface); ok {
+		return uv.Interface().(interface{ IsZero() bool }).IsZero()
+	}
+	return v.IsZero()
+}
+
+// isNilValue returns true if v is a nil pointer or interface, or a chain of pointers ending in one.
+func isNilValue(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return true
+		}
+		if v.Kind() == reflect.Interface {
+			return false
+		}
+		v = v.Elem()
+	}
+	return false
+}
+
+// countingWriter discards output, counting the bytes written.
+type countingWriter struct {
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += len(p)
+	return len(p), nil
+}
+
+// lineEndingWriter replaces all newlines written to it with an alternate line ending.
+type lineEndingWriter struct {
+	w   io.Writer
+	eol []byte
+}
+
+func (l *lineEndingWriter) Write(p []byte) (int, error) {
+	_, err := l.w.Write(bytes.ReplaceAll(p, []byte("\n"), l.eol))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
 }
 
 func marshalToAST(v interface{}, schema bool, opt *marshalOptions) (*AST, error) {
+	if opt.goDocErr != nil {
+		return nil, opt.goDocErr
+	}
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr {
-		return nil, fmt.Errorf("expected a pointer to a struct, not %T", v)
+		return nil, fmt.Errorf("expected a pointer to a struct, map or slice, not %T", v)
 	}
 	rv = rv.Elem()
-	if rv.Kind() != reflect.Struct {
+	if rv.Kind() != reflect.Struct && schema {
 		return nil, fmt.Errorf("expected a pointer to a struct, not %T", v)
 	}
 	var (
-		err    error
-		labels []string
-		ast    = &AST{
+		err error
+		ast = &AST{
 			Schema: schema,
 		}
 	)
-	ast.Entries, labels, err = structToEntries(rv, schema, opt)
+	ast.Entries, err = topLevelEntries(rv, schema, opt)
 	if err != nil {
 		return nil, err
 	}
-	if len(labels) > 0 {
-		return nil, fmt.Errorf("unexpected labels %s at top level", strings.Join(labels, ", "))
-	}
 	return ast, nil
 }
 
+// topLevelEntries converts a struct, a map, or a slice of either, into top-level entries.
+func topLevelEntries(rv reflect.Value, schema bool, opt *marshalOptions) ([]*Entry, error) {
+	switch rv.Kind() {
+	case reflect.Struct:
+		entries, labels, err := structToEntries(rv, schema, opt)
+		if err != nil {
+			return nil, err
+		}
+		if len(labels) > 0 {
+			return nil, fmt.Errorf("unexpected labels %s at top level", strings.Join(labels, ", "))
+		}
+		return entries, nil
+
+	case reflect.Map:
+		return mapToEntries(rv, opt)
+
+	case reflect.Slice:
+		entries := []*Entry{}
+		for i := 0; i < rv.Len(); i++ {
+			el := rv.Index(i)
+			for el.Kind() == reflect.Ptr || el.Kind() == reflect.Interface {
+				el = el.Elem()
+			}
+			if el.Kind() != reflect.Struct && el.Kind() != reflect.Map {
+				return nil, fmt.Errorf("expected a struct or map at index %d but got %s", i, el.Kind())
+			}
+			elEntries, err := topLevelEntries(el, schema, opt.descendIndex(i))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, elEntries...)
+		}
+		return entries, nil
+
+	default:
+		return nil, fmt.Errorf("expected a pointer to a struct, map or slice, not %s", rv.Type())
+	}
+}
+
+// mapToEntries converts a map with string keys into entries, sorted by key.
+//
+// Struct values become blocks named after their key, while all other values become attributes.
+// This includes structs held in interface values, which are marshalled as objects.
+func mapToEntries(v reflect.Value, opt *marshalOptions) ([]*Entry, error) {
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("map keys must be strings but we have %s", v.Type().Key())
+	}
+	keys := sortedMapKeys(v, opt)
+	entries := []*Entry{}
+	for _, key := range keys {
+		value := v.MapIndex(key)
+		elt := value.Type()
+		if elt.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				continue
+			}
+			elt = elt.Elem()
+		}
+		if elt.Kind() == reflect.Struct && !isValueType(elt) {
+			block, err := valueToBlock(value, tag{name: key.String(), block: true}, false, opt.descend(key.String()))
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, &Entry{Block: block})
+			continue
+		}
+		attr, err := valueToValue(value, opt.descend(key.String()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &Entry{Attribute: &Attribute{Key: key.String(), Value: attr}})
+	}
+	return entries, nil
+}
+
+// sortedMapKeys returns the keys of a map sorted alphabetically, after any keys given for the
+// map's path by MapKeyOrder.
+func sortedMapKeys(v reflect.Value, opt *marshalOptions) []reflect.Value {
+	rank := map[string]int{}
+	for i, key := range opt.mapKeyOrder[opt.path] {
+		if _, ok := rank[key]; !ok {
+			rank[key] = i
+		}
+	}
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		ri, iok := rank[keys[i].String()]
+		rj, jok := rank[keys[j].String()]
+		switch {
+		case iok && jok:
+			return ri < rj
+		case iok != jok:
+			return iok
+		default:
+			return keys[i].String() < keys[j].String()
+		}
+	})
+	return keys
+}
+
+// inlineMapToEntries converts the entries of an inline map field into attributes, ordered by key.
+func inlineMapToEntries(v reflect.Value, opt *marshalOptions) ([]*Entry, error) {
+	keys := sortedMapKeys(v, opt)
+	entries := make([]*Entry, 0, len(keys))
+	for _, key := range keys {
+		value, err := valueToValue(v.MapIndex(key), opt.descend(key.String()))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &Entry{Attribute: &Attribute{Key: key.String(), Value: value}})
+	}
+	return entries, nil
+}
+
 func structToEntries(v reflect.Value, schema bool, opt *marshalOptions) (entries []*Entry, labels []string, err error) {
 	if v.Kind() == reflect.Ptr {
 		if v.IsNil() {
@@ -105,13 +1006,62 @@ func structToEntries(v reflect.Value, schema bool, opt *marshalOptions) (entries
 		}
 		v = v.Elem()
 	}
+	if opt.maxDepth > 0 {
+		if opt.depth >= opt.maxDepth {
+			return nil, nil, fmt.Errorf("maximum depth of %d exceeded at %q", opt.maxDepth, opt.path)
+		}
+		child := *opt
+		child.depth++
+		opt = &child
+	}
+	if em, ok := implements(v, entriesMarshalerInterface); ok && !schema {
+		entries, err := em.Interface().(EntriesMarshaler).MarshalHCLEntries()
+		return entries, nil, err
+	}
 	fields, err := flattenFields(v)
 	if err != nil {
 		return nil, nil, err
 	}
+	// Values of "comments" fields, keyed by the entry they are attached to.
+	siblingComments := map[string][]string{}
 	for _, field := range fields {
-		tag := parseTag(v.Type(), field, opt)
+		tag, err := parseTag(v.Type(), field, opt)
+		if err != nil {
+			return nil, nil, err
+		}
+		if tag.help == "" {
+			tag.help = opt.goDoc(v.Type(), field.t.Name)
+		}
+		if schema && opt.schemaValidationTag != "" {
+			if constraints := field.t.Tag.Get(opt.schemaValidationTag); constraints != "" {
+				if tag.help != "" {
+					tag.help += "\n"
+				}
+				tag.help += strings.Join(strings.Split(constraints, ","), " ")
+			}
+		}
+		fopt := opt.descend(tag.name)
 		switch {
+		case tag.commentsField:
+			if tag.name != "" && !schema && field.v.Len() > 0 {
+				siblingComments[tag.name] = field.v.Interface().([]string)
+			}
+
+		case tag.name == "":
+
+		case field.embed != nil && !schema:
+			// Fields of nil embedded pointer structs are omitted.
+
+		case tag.inline:
+			if schema {
+				continue
+			}
+			attrs, err := inlineMapToEntries(field.v, fopt)
+			if err != nil {
+				return nil, nil, err
+			}
+			entries = append(entries, attrs...)
+
 		case tag.label:
 			if schema {
 				labels = append(labels, tag.name)
@@ -119,17 +1069,20 @@ func structToEntries(v reflect.Value, schema bool, opt *marshalOptions) (entries
 				labels = append(labels, field.v.String())
 			}
 
+		case tag.omitZero && isZero(field.v) && !schema:
+			// Zero values are omitted.
+
 		case tag.block:
 			if field.v.Kind() == reflect.Slice {
 				var blocks []*Block
 				if schema {
-					block, err := sliceToBlockSchema(field.v.Type(), tag, opt)
+					block, err := sliceToBlockSchema(field.v.Type(), tag, fopt)
 					if err == nil {
 						block.Repeated = true
 						blocks = append(blocks, block)
 					}
 				} else {
-					blocks, err = sliceToBlocks(field.v, tag, opt)
+					blocks, err = sliceToBlocks(field.v, tag, fopt)
 				}
 				if err != nil {
 					return nil, nil, err
@@ -137,28 +1090,41 @@ func structToEntries(v reflect.Value, schema bool, opt *marshalOptions) (entries
 				for _, block := range blocks {
 					entries = append(entries, &Entry{Block: block})
 				}
+			} else if (field.v.Kind() == reflect.Ptr || field.v.Kind() == reflect.Map) && field.v.IsNil() && !schema {
+				// Absent optional blocks are omitted.
+				continue
 			} else {
-				block, err := valueToBlock(field.v, tag, schema, opt)
+				block, err := valueToBlock(field.v, tag, schema, fopt)
 				if err != nil {
 					return nil, nil, err
 				}
 				entries = append(entries, &Entry{Block: block})
 			}
 
-		case tag.optional && field.v.IsZero() && !schema:
+		case tag.optional && (field.v.IsZero() || isNilValue(field.v)) && !schema:
 
 		default:
-			attr, err := fieldToAttr(field, tag, schema)
+			attr, err := fieldToAttr(field, tag, schema, fopt)
+			if _, ok := err.(unsupportedTypeError); ok && opt.skipUnsupported {
+				continue
+			}
 			if err != nil {
 				return nil, nil, err
 			}
 			entries = append(entries, &Entry{Attribute: attr})
 		}
 	}
+	entries = groupAttributes(entries)
+	for _, entry := range entries {
+		if comments, ok := siblingComments[entry.Key()]; ok {
+			setEntryComments(entry, comments)
+			delete(siblingComments, entry.Key())
+		}
+	}
 	return entries, labels, nil
 }
 
-func fieldToAttr(field field, tag tag, schema bool) (*Attribute, error) {
+func fieldToAttr(field field, tag tag, schema bool, opt *marshalOptions) (*Attribute, error) {
 	attr := &Attribute{
 		Key:      tag.name,
 		Comments: tag.comments(),
@@ -166,17 +1132,107 @@ func fieldToAttr(field field, tag tag, schema bool) (*Attribute, error) {
 	var err error
 	if schema {
 		attr.Value, err = attrSchema(field.v.Type())
+		if err != nil {
+			return nil, err
+		}
+		if tag.optional && opt.commentOptionals {
+			attr.Commented = true
+			if def, ok := field.t.Tag.Lookup("default"); ok {
+				attr.Value, err = parseDefault(field.t.Type, def)
+				if err != nil {
+					return nil, fmt.Errorf("invalid default for %s: %s", field.t.Name, err)
+				}
+			} else {
+				attr.Value, err = valueToValue(field.v, opt)
+				if err != nil {
+					return nil, err
+				}
+			}
+		} else if def, ok := field.t.Tag.Lookup("default"); ok && opt.schemaDefaults {
+			attr.Value, err = parseDefault(field.t.Type, def)
+			if err != nil {
+				return nil, fmt.Errorf("invalid default for %s: %s", field.t.Name, err)
+			}
+		} else if opt.schemaSamples {
+			attr.Value = sampleValue(attr.Value)
+		}
 	} else {
-		attr.Value, err = valueToValue(field.v)
+		vopt := opt
+		if opt.rawIntegers && (tag.radix != 0 || tag.unit != "") {
+			// Radixes and units are applied to numeric values.
+			vopt = &marshalOptions{}
+			*vopt = *opt
+			vopt.rawIntegers = false
+		}
+		attr.Value, err = valueToValue(field.v, vopt)
+		if err == nil && tag.radix != 0 {
+			setRadix(attr.Value, tag.radix)
+		}
+		if err == nil && tag.unit != "" {
+			setUnit(attr.Value, tag.unit)
+		}
+		if err == nil && tag.multiline && attr.Value.Str != nil {
+			attr.Value = stringToHeredoc(unescapeTemplates(*attr.Value.Str))
+		}
+		if err == nil && tag.lines && len(attr.Value.List) > 0 {
+			attr.Value, err = linesToHeredoc(field.v, field.t.Name)
+		}
+		if err == nil && tag.ident && attr.Value.Str != nil {
+			if !isIdentValue(*attr.Value.Str) {
+				return nil, fmt.Errorf("value %q of %s is not a valid identifier", *attr.Value.Str, field.t.Name)
+			}
+			attr.Value.Ident = true
+		}
 	}
-	attr.Optional = tag.optional && schema
+	attr.Optional = tag.optional && schema && !attr.Commented
+	attr.Group = tag.group
 	return attr, err
 }
 
-func valueToValue(v reflect.Value) (*Value, error) {
+func valueToValue(v reflect.Value, opt *marshalOptions) (*Value, error) {
 	// Special cased types.
 	t := v.Type()
-	if t == durationType {
+	if format, ok := opt.valueFormatters[t]; ok {
+		text, err := format(v)
+		if err != nil {
+			return nil, err
+		}
+		value, err := parseValue(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid formatted %s value %q: %s", t, text, err)
+		}
+		value.Raw = text
+		return value, nil
+	} else if registered, ok := typeRegistry[t]; ok && registered.marshal != nil {
+		return registered.marshal(v)
+	} else if t == expressionType {
+		s := v.String()
+		return &Value{Expr: &s}, nil
+	} else if t == jsonNumberType {
+		// As with encoding/json, an empty json.Number is zero.
+		text := v.String()
+		if text == "" {
+			text = "0"
+		}
+		n, _, err := big.ParseFloat(text, 0, numberPrecision(len(text)), big.ToNearestEven)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json.Number %q: %s", text, err)
+		}
+		value := quoteScalar(&Value{Number: n}, opt)
+		if value.Number != nil && jsonNumberRe.MatchString(text) {
+			value.Raw = text
+		}
+		return value, nil
+	} else if t == bigFloatType || t == reflect.PtrTo(bigFloatType) {
+		if t.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return &Value{Null: true}, nil
+			}
+			v = v.Elem()
+		}
+		f := v.Interface().(big.Float)
+		return quoteScalar(&Value{Number: new(big.Float).Copy(&f)}, opt), nil
+	} else if t == durationType {
 		s := v.Interface().(time.Duration).String()
 		return &Value{Str: &s}, nil
 	} else if uv, ok := implements(v, textMarshalerInterface); ok {
@@ -195,35 +1251,49 @@ func valueToValue(v reflect.Value) (*Value, error) {
 		}
 		s := string(b)
 		return &Value{Str: &s}, nil
+	} else if uv, ok := implements(v, binaryMarshalerInterface); ok {
+		bm := uv.Interface().(encoding.BinaryMarshaler)
+		b, err := bm.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		s := base64.StdEncoding.EncodeToString(b)
+		return &Value{Str: &s}, nil
+	} else if opt.marshalStringers && !isNilValue(v) {
+		if uv, ok := implements(v, errorInterface); ok {
+			s := escapeTemplates(uv.Interface().(error).Error())
+			return &Value{Str: &s}, nil
+		} else if uv, ok := implements(v, stringerInterface); ok {
+			s := escapeTemplates(uv.Interface().(fmt.Stringer).String())
+			return &Value{Str: &s}, nil
+		}
 	}
 	switch t.Kind() {
 	case reflect.String:
-		s := v.Interface().(string)
+		s := escapeTemplates(v.String())
 		return &Value{Str: &s}, nil
 
-	case reflect.Slice:
+	case reflect.Slice, reflect.Array:
 		list := []*Value{}
 		for i := 0; i < v.Len(); i++ {
 			el := v.Index(i)
-			elv, err := valueToValue(el)
+			elv, err := valueToValue(el, opt.descendIndex(i))
 			if err != nil {
 				return nil, err
 			}
 			list = append(list, elv)
 		}
+		if opt.sortLists {
+			sort.SliceStable(list, func(i, j int) bool {
+				return list[i].String() < list[j].String()
+			})
+		}
 		return &Value{List: list, HaveList: true}, nil
 
 	case reflect.Map:
 		entries := []*MapEntry{}
-		sorted := []reflect.Value{}
-		for _, key := range v.MapKeys() {
-			sorted = append(sorted, key)
-		}
-		sort.Slice(sorted, func(i, j int) bool {
-			return sorted[i].String() < sorted[j].String()
-		})
-		for _, key := range sorted {
-			value, err := valueToValue(v.MapIndex(key))
+		for _, key := range sortedMapKeys(v, opt) {
+			value, err := valueToValue(v.MapIndex(key), opt.descend(key.String()))
 			if err != nil {
 				return nil, err
 			}
@@ -236,28 +1306,187 @@ func valueToValue(v reflect.Value) (*Value, error) {
 		return &Value{Map: entries, HaveMap: true}, nil
 
 	case reflect.Float32, reflect.Float64:
-		return &Value{Number: big.NewFloat(v.Float())}, nil
+		// Use the precision of the Go type, so that values are formatted with the shortest
+		// representation that round-trips, eg. 0.1 rather than 0.10000000149011612 for a float32.
+		prec := uint(53)
+		if v.Kind() == reflect.Float32 {
+			prec = 24
+		}
+		n := new(big.Float).SetPrec(prec).SetFloat64(v.Float())
+		return quoteScalar(&Value{Number: n}, opt), nil
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return &Value{Number: big.NewFloat(0).SetInt64(v.Int())}, nil
+		if opt.rawIntegers {
+			return quoteScalar(&Value{Raw: strconv.FormatInt(v.Int(), 10)}, opt), nil
+		}
+		return quoteScalar(&Value{Number: new(big.Float).SetInt64(v.Int())}, opt), nil
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return &Value{Number: big.NewFloat(0).SetUint64(v.Uint())}, nil
+		if opt.rawIntegers {
+			return quoteScalar(&Value{Raw: strconv.FormatUint(v.Uint(), 10)}, opt), nil
+		}
+		return quoteScalar(&Value{Number: new(big.Float).SetUint64(v.Uint())}, opt), nil
 
 	case reflect.Bool:
 		b := v.Bool()
-		return &Value{Bool: (*Bool)(&b)}, nil
+		return quoteScalar(&Value{Bool: (*Bool)(&b)}, opt), nil
 
-	default:
-		switch t {
-		case timeType:
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return &Value{Null: true}, nil
+		}
+		return valueToValue(v.Elem(), opt)
+
+	case reflect.Struct:
+		if t == timeType {
 			s := v.Interface().(time.Time).Format(time.RFC3339)
 			return &Value{Str: &s}, nil
+		}
+		return structToValue(v, opt)
 
-		default:
-			panic(t.String())
+	default:
+		return nil, unsupportedTypeError{t}
+	}
+}
+
+// isIdentValue returns true if s can be written as a bare identifier and read back as a string.
+func isIdentValue(s string) bool {
+	switch s {
+	case "true", "false", "null":
+		return false
+	}
+	return identifierRe.MatchString(s)
+}
+
+// stringToHeredoc converts a string into a heredoc value.
+//
+// The delimiter is "EOF", suffixed with a number if the string contains a line matching it.
+func stringToHeredoc(s string) *Value {
+	lines := map[string]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		lines[strings.TrimSpace(line)] = true
+	}
+	delimiter := "EOF"
+	for i := 1; lines[delimiter]; i++ {
+		delimiter = fmt.Sprintf("EOF%d", i)
+	}
+	body := "\n" + s
+	return &Value{HeredocDelimiter: delimiter, Heredoc: &body}
+}
+
+// linesToHeredoc converts a string slice into a heredoc with one element per line.
+func linesToHeredoc(v reflect.Value, name string) (*Value, error) {
+	lines := make([]string, v.Len())
+	for i := range lines {
+		lines[i] = v.Index(i).String()
+		if strings.Contains(lines[i], "\n") {
+			return nil, fmt.Errorf("line %q of %s contains a newline", lines[i], name)
+		}
+	}
+	return stringToHeredoc(strings.Join(lines, "\n")), nil
+}
+
+// setRadix sets the radix of a number, or of the numbers in a list.
+func setRadix(value *Value, radix int) {
+	if value.Number != nil {
+		value.Radix = radix
+	}
+	for _, el := range value.List {
+		setRadix(el, radix)
+	}
+}
+
+// setUnit converts a number, or the numbers in a list, into strings with the given unit suffix.
+func setUnit(value *Value, unit string) {
+	if value.Number != nil {
+		s := formatNumber(value.Number) + unit
+		*value = Value{Str: &s}
+	}
+	for _, el := range value.List {
+		setUnit(el, unit)
+	}
+}
+
+// structToValue converts a struct into a map Value keyed by the HCL names of its fields.
+func structToValue(v reflect.Value, opt *marshalOptions) (*Value, error) {
+	entries, labels, err := structToEntries(v, false, opt)
+	if err != nil {
+		return nil, err
+	}
+	if len(labels) > 0 {
+		return nil, fmt.Errorf("%s: labels are not supported in struct values", v.Type())
+	}
+	return entriesToValue(entries)
+}
+
+// entriesToValue converts entries into a map Value, with blocks becoming nested maps and repeated
+// blocks becoming lists of maps.
+func entriesToValue(entries []*Entry) (*Value, error) {
+	out := &Value{HaveMap: true, Map: []*MapEntry{}}
+	index := map[string]*MapEntry{}
+	for _, entry := range entries {
+		key := entry.Key()
+		if entry.Attribute != nil {
+			out.Map = append(out.Map, &MapEntry{
+				Comments: entry.Attribute.Comments,
+				Key:      &Value{Str: &key},
+				Value:    entry.Attribute.Value,
+			})
+			continue
+		}
+		if len(entry.Block.Labels) > 0 {
+			return nil, fmt.Errorf("block %q: labels are not supported in struct values", key)
+		}
+		value, err := entriesToValue(entry.Block.Body)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := index[key]; ok {
+			if !existing.Value.HaveList {
+				existing.Value = &Value{HaveList: true, List: []*Value{existing.Value}}
+			}
+			existing.Value.List = append(existing.Value.List, value)
+			continue
 		}
+		mapEntry := &MapEntry{Comments: entry.Block.Comments, Key: &Value{Str: &key}, Value: value}
+		index[key] = mapEntry
+		out.Map = append(out.Map, mapEntry)
+	}
+	return out, nil
+}
+
+var (
+	templateEscaper   = strings.NewReplacer("${", "$${", "%{", "%%{")
+	templateUnescaper = strings.NewReplacer("$${", "${", "%%{", "%{")
+)
+
+// escapeTemplates escapes "${" and "%{" sequences so they are not treated as
+// interpolation or template directives when the output is re-parsed.
+func escapeTemplates(s string) string {
+	return templateEscaper.Replace(s)
+}
+
+// unescapeTemplates reverses escapeTemplates.
+func unescapeTemplates(s string) string {
+	return templateUnescaper.Replace(s)
+}
+
+// quoteScalar converts a number or bool to a string if QuoteAll(true) is set.
+func quoteScalar(value *Value, opt *marshalOptions) *Value {
+	if !opt.quoteAll {
+		return value
 	}
+	s := value.String()
+	return &Value{Str: &s}
+}
+
+// unsupportedTypeError is returned when a Go type can't be represented in HCL.
+type unsupportedTypeError struct {
+	t reflect.Type
+}
+
+func (u unsupportedTypeError) Error() string {
+	return fmt.Sprintf("unsupported type %s", u.t)
 }
 
 func valueToBlock(v reflect.Value, tag tag, schema bool, opt *marshalOptions) (*Block, error) {
@@ -266,62 +1495,189 @@ func valueToBlock(v reflect.Value, tag tag, schema bool, opt *marshalOptions) (*
 		Comments: tag.comments(),
 	}
 	var err error
+	if v.Kind() == reflect.Map {
+		// A map tagged as a block is marshalled as a block of attributes.
+		if !schema {
+			block.Body, err = mapToEntries(v, opt)
+		}
+		return block, err
+	}
 	block.Body, block.Labels, err = structToEntries(v, schema, opt)
+	if err != nil || schema {
+		return block, err
+	}
+	comments, err := enclosingComments(v, opt)
+	if len(comments) > 0 {
+		block.Comments = comments
+	}
 	return block, err
 }
 
+// enclosingComments returns the value of an unnamed "comments" field of a struct, if any.
+func enclosingComments(v reflect.Value, opt *marshalOptions) ([]string, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	fields, err := flattenFields(v)
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range fields {
+		tag, err := parseTag(v.Type(), field, opt)
+		if err != nil {
+			return nil, err
+		}
+		if tag.commentsField && tag.name == "" {
+			return field.v.Interface().([]string), nil
+		}
+	}
+	return nil, nil
+}
+
+func setEntryComments(entry *Entry, comments []string) {
+	if entry.Block != nil {
+		entry.Block.Comments = comments
+	} else {
+		entry.Attribute.Comments = comments
+	}
+}
+
 func sliceToBlocks(sv reflect.Value, tag tag, opt *marshalOptions) ([]*Block, error) {
 	blocks := []*Block{}
 	for i := 0; i != sv.Len(); i++ {
-		block, err := valueToBlock(sv.Index(i), tag, false, opt)
+		block, err := valueToBlock(sv.Index(i), tag, false, opt.descendIndex(i))
 		if err != nil {
 			return nil, err
 		}
+		if opt.indexComments {
+			block.Comments = append(block.Comments, fmt.Sprintf("[%d]", i))
+		}
 		blocks = append(blocks, block)
 	}
 	return blocks, nil
 }
 
-func marshalNode(w io.Writer, indent string, node Node) error {
+// Matches keys that can be written without quoting.
+var identifierRe = regexp.MustCompile(`^[[:alpha:]]\w*(-\w+)*$`)
+
+func marshalNode(w io.Writer, indent string, node Node, opt *marshalOptions) error {
+	if opt.compact {
+		return marshalCompactNode(w, node, opt)
+	}
 	switch node := node.(type) {
 	case *AST:
-		return marshalAST(w, indent, node)
+		return marshalAST(w, indent, node, opt)
 	case *Block:
-		return marshalBlock(w, indent, node)
+		return marshalBlock(w, indent, node, opt)
 	case *Attribute:
-		return marshalAttribute(w, indent, node)
+		return marshalAttribute(w, indent, node, opt)
 	case *Value:
-		return marshalValue(w, indent, node)
+		return marshalValue(w, indent, len(indent), node, opt)
 	default:
 		return fmt.Errorf("can't marshal node of type %T", node)
 	}
 }
 
-func marshalAST(w io.Writer, indent string, node *AST) error {
-	err := marshalEntries(w, indent, node.Entries)
+func marshalAST(w io.Writer, indent string, node *AST, opt *marshalOptions) error {
+	if opt.baseline != nil {
+		withChanges := *opt
+		withChanges.changes = map[*Attribute]string{}
+		changedAttributes(node.Entries, opt.baseline.Entries, withChanges.changes)
+		opt = &withChanges
+	}
+	leading := node.LeadingComments
+	if opt.header != "" {
+		leading = append([]string{strings.TrimSuffix(opt.header, "\n")}, leading...)
+	}
+	if len(leading) > 0 {
+		marshalComments(w, indent, leading, opt)
+		if len(node.Entries) > 0 {
+			fmt.Fprintln(w)
+		}
+	}
+	err := marshalEntries(w, indent, node.Entries, opt)
 	if err != nil {
 		return err
 	}
-	marshalComments(w, indent, node.TrailingComments)
+	marshalComments(w, indent, node.TrailingComments, opt)
 	return nil
 }
 
-func marshalEntries(w io.Writer, indent string, entries []*Entry) error {
+// groupAttributes returns entries with attributes of the same group clustered together, leaving blocks in place.
+//
+// Groups are ordered by their first attribute, and attributes keep their order within a group.
+func groupAttributes(entries []*Entry) []*Entry {
+	rank := map[string]int{}
+	for _, entry := range entries {
+		if entry.Attribute == nil {
+			continue
+		}
+		if _, ok := rank[entry.Attribute.Group]; !ok {
+			rank[entry.Attribute.Group] = len(rank)
+		}
+	}
+	if len(rank) < 2 {
+		return entries
+	}
+	order := map[string]int{}
+	for _, entry := range entries {
+		if entry.Attribute != nil {
+			order[entry.Attribute.Key] = rank[entry.Attribute.Group]
+		}
+	}
+	return orderAttributes(entries, order)
+}
+
+// orderAttributes returns a copy of entries with the attributes reordered according to order,
+// leaving blocks in place.
+func orderAttributes(entries []*Entry, order map[string]int) []*Entry {
+	rank := func(entry *Entry) int {
+		if i, ok := order[entry.Attribute.Key]; ok {
+			return i
+		}
+		return len(order)
+	}
+	attrs := []*Entry{}
+	for _, entry := range entries {
+		if entry.Attribute != nil {
+			attrs = append(attrs, entry)
+		}
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		return rank(attrs[i]) < rank(attrs[j])
+	})
+	out := make([]*Entry, len(entries))
+	for i, entry := range entries {
+		if entry.Attribute != nil {
+			entry, attrs = attrs[0], attrs[1:]
+		}
+		out[i] = entry
+	}
+	return out
+}
+
+func marshalEntries(w io.Writer, indent string, entries []*Entry, opt *marshalOptions) error {
+	if opt.attributeOrder != nil {
+		entries = orderAttributes(entries, opt.attributeOrder)
+	}
 	prevAttr := true
 	for i, entry := range entries {
 		if block := entry.Block; block != nil {
 			if i > 0 {
 				fmt.Fprintln(w)
 			}
-			if err := marshalBlock(w, indent, block); err != nil {
+			if err := marshalBlock(w, indent, block, opt); err != nil {
 				return err
 			}
 			prevAttr = false
 		} else if attr := entry.Attribute; attr != nil {
-			if !prevAttr {
+			if !prevAttr || (i > 0 && attr.Group != entries[i-1].Attribute.Group) {
 				fmt.Fprintln(w)
 			}
-			if err := marshalAttribute(w, indent, attr); err != nil {
+			if err := marshalAttribute(w, indent, attr, opt); err != nil {
 				return err
 			}
 			prevAttr = true
@@ -332,34 +1688,206 @@ func marshalEntries(w io.Writer, indent string, entries []*Entry) error {
 	return nil
 }
 
-func marshalAttribute(w io.Writer, indent string, attribute *Attribute) error {
-	marshalComments(w, indent, attribute.Comments)
-	fmt.Fprintf(w, "%s%s = ", indent, attribute.Key)
-	err := marshalValue(w, indent, attribute.Value)
+func marshalAttribute(w io.Writer, indent string, attribute *Attribute, opt *marshalOptions) error {
+	key := attribute.Key
+	if !identifierRe.MatchString(key) {
+		if opt.strictKeys {
+			return fmt.Errorf("attribute key %q is not a valid identifier", key)
+		}
+		key = quoteString(key, opt)
+	}
+	marshalComments(w, indent, attribute.Comments, opt)
+	if attribute.Commented {
+		return marshalCommentedAttribute(w, indent, attribute, opt)
+	}
+	fmt.Fprintf(w, "%s%s = ", indent, key)
+	err := marshalValue(w, indent, len(indent)+len(key)+3, attribute.Value, opt)
 	if err != nil {
 		return err
 	}
 	if attribute.Optional {
 		fmt.Fprint(w, " // (optional)")
 	}
+	if was, ok := opt.changes[attribute]; ok {
+		fmt.Fprintf(w, " // was %s", was)
+	}
 	fmt.Fprintln(w)
 	return nil
 }
 
-func marshalValue(w io.Writer, indent string, value *Value) error {
-	if value.HaveMap {
-		return marshalMap(w, indent+"  ", value.Map)
+// marshalCommentedAttribute writes each line of an attribute, without its comments, as a comment.
+func marshalCommentedAttribute(w io.Writer, indent string, attribute *Attribute, opt *marshalOptions) error {
+	uncommented := *attribute
+	uncommented.Comments = nil
+	uncommented.Commented = false
+	buf := &bytes.Buffer{}
+	err := marshalAttribute(buf, "", &uncommented, opt)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		fmt.Fprintf(w, "%s// %s\n", indent, line)
+	}
+	return nil
+}
+
+// changedAttributes records the baseline value of each attribute in entries whose value differs,
+// written on a single line.
+func changedAttributes(entries, baseline []*Entry, changes map[*Attribute]string) {
+	attrs := map[string]*Attribute{}
+	for _, entry := range baseline {
+		if entry.Attribute != nil {
+			attrs[entry.Attribute.Key] = entry.Attribute
+		}
+	}
+	used := map[*Block]bool{}
+	for _, entry := range entries {
+		if attr := entry.Attribute; attr != nil {
+			old, ok := attrs[attr.Key]
+			if !ok {
+				continue
+			}
+			if !equalValues(old.Value, attr.Value) {
+				// Compact output writes heredocs as quoted strings, so that the comment is a single line.
+				changes[attr] = inlineString(old.Value, &marshalOptions{compact: true})
+			}
+			continue
+		}
+		for _, candidate := range baseline {
+			block := candidate.Block
+			if block != nil && !used[block] && block.Name == entry.Block.Name && equalStrings(block.Labels, entry.Block.Labels) {
+				used[block] = true
+				changedAttributes(entry.Block.Body, block.Body, changes)
+				break
+			}
+		}
+	}
+}
+
+// marshalValue writes a value, where "width" is the number of other characters on its line.
+func marshalValue(w io.Writer, indent string, width int, value *Value, opt *marshalOptions) error {
+	if value.HaveMap && value.Raw == "" {
+		return marshalMap(w, indent+"  ", value.Map, opt)
+	}
+	if value.HaveList && len(value.List) > 0 && value.Raw == "" {
+		if opt.wrapListsOver > 0 && len(value.List) > opt.wrapListsOver {
+			return marshalList(w, indent+"  ", value.List, opt)
+		}
+		if opt.maxLineLength > 0 && width+inlineLength(value, opt) > opt.maxLineLength {
+			return marshalList(w, indent+"  ", value.List, opt)
+		}
+	}
+	marshalInlineValue(w, value, opt)
+	return nil
+}
+
+// inlineLength returns the length of a value when written on a single line.
+func inlineLength(value *Value, opt *marshalOptions) int {
+	return len(inlineString(value, opt))
+}
+
+// inlineString returns a value written on a single line.
+func inlineString(value *Value, opt *marshalOptions) string {
+	w := &strings.Builder{}
+	marshalInlineValue(w, value, opt)
+	return w.String()
+}
+
+// marshalInlineValue writes a value on a single line.
+func marshalInlineValue(w io.Writer, value *Value, opt *marshalOptions) {
+	switch {
+	case value.Raw != "":
+		_, _ = io.WriteString(w, value.Raw)
+
+	case value.HaveList:
+		fmt.Fprint(w, "[")
+		for i, element := range value.List {
+			if i > 0 {
+				fmt.Fprint(w, ", ")
+			}
+			marshalInlineValue(w, element, opt)
+		}
+		fmt.Fprint(w, "]")
+
+	case value.HaveMap:
+		fmt.Fprint(w, "{")
+		for i, entry := range value.Map {
+			if i > 0 {
+				fmt.Fprint(w, ", ")
+			}
+			fmt.Fprintf(w, "%s%s", inlineString(entry.Key, opt), mapSeparator(opt))
+			marshalInlineValue(w, entry.Value, opt)
+		}
+		fmt.Fprint(w, "}")
+
+	case value.HeredocDelimiter != "" && (opt.compact || opt.asciiOnly):
+		fmt.Fprint(w, quoteString(value.GetHeredoc(), opt))
+
+	case value.Str != nil && !value.Ident && opt.asciiOnly:
+		fmt.Fprint(w, quoteString(*value.Str, opt))
+
+	case value.Number != nil && value.Radix == 0 && opt.digitGrouping:
+		fmt.Fprint(w, groupDigits(value.String()))
+
+	default:
+		fmt.Fprintf(w, "%s", value)
+	}
+}
+
+// quoteString quotes a string, escaping non-ASCII characters if ASCIIOnly is set.
+func quoteString(s string, opt *marshalOptions) string {
+	if opt.asciiOnly {
+		return strconv.QuoteToASCII(s)
+	}
+	return strconv.Quote(s)
+}
+
+// groupDigits inserts underscores between every three digits of an integer literal.
+//
+// Non-integer literals are returned unchanged.
+func groupDigits(s string) string {
+	digits := strings.TrimLeft(s, "+-")
+	if strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) != -1 {
+		return s
+	}
+	out := s[:len(s)-len(digits)]
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			out += "_"
+		}
+		out += string(r)
+	}
+	return out
+}
+
+func mapSeparator(opt *marshalOptions) string {
+	if opt.mapSeparator == "=" {
+		return " = "
+	}
+	return ": "
+}
+
+func marshalList(w io.Writer, indent string, elements []*Value, opt *marshalOptions) error {
+	fmt.Fprintln(w, "[")
+	for _, element := range elements {
+		fmt.Fprint(w, indent)
+		// Account for the trailing comma.
+		if err := marshalValue(w, indent, len(indent)+1, element, opt); err != nil {
+			return err
+		}
+		fmt.Fprintln(w, ",")
 	}
-	fmt.Fprintf(w, "%s", value)
+	fmt.Fprintf(w, "%s]", indent[:len(indent)-2])
 	return nil
 }
 
-func marshalMap(w io.Writer, indent string, entries []*MapEntry) error {
+func marshalMap(w io.Writer, indent string, entries []*MapEntry, opt *marshalOptions) error {
 	fmt.Fprintln(w, "{")
 	for _, entry := range entries {
-		marshalComments(w, indent, entry.Comments)
-		fmt.Fprintf(w, "%s%s: ", indent, entry.Key)
-		if err := marshalValue(w, indent+"  ", entry.Value); err != nil {
+		marshalComments(w, indent, entry.Comments, opt)
+		prefix := fmt.Sprintf("%s%s%s", indent, inlineString(entry.Key, opt), mapSeparator(opt))
+		fmt.Fprint(w, prefix)
+		if err := marshalValue(w, indent, len(prefix)+1, entry.Value, opt); err != nil {
 			return err
 		}
 		fmt.Fprintln(w, ",")
@@ -368,18 +1896,30 @@ func marshalMap(w io.Writer, indent string, entries []*MapEntry) error {
 	return nil
 }
 
-func marshalBlock(w io.Writer, indent string, block *Block) error {
-	marshalComments(w, indent, block.Comments)
+func marshalBlock(w io.Writer, indent string, block *Block, opt *marshalOptions) error {
+	marshalComments(w, indent, block.Comments, opt)
 	fmt.Fprintf(w, "%s%s ", indent, block.Name)
 	for _, label := range block.Labels {
-		fmt.Fprintf(w, "%q ", label)
+		if opt.unquotedLabels && identifierRe.MatchString(label) {
+			fmt.Fprintf(w, "%s ", label)
+		} else {
+			fmt.Fprintf(w, "%s ", quoteString(label, opt))
+		}
+	}
+	if opt.compactEmptyBlocks && len(block.Body) == 0 && len(block.TrailingComments) == 0 {
+		if block.Repeated {
+			fmt.Fprintln(w, "{} // (repeated)")
+		} else {
+			fmt.Fprintln(w, "{}")
+		}
+		return nil
 	}
 	if block.Repeated {
 		fmt.Fprintln(w, "{ // (repeated)")
 	} else {
 		fmt.Fprintln(w, "{")
 	}
-	err := marshalEntries(w, indent+"  ", block.Body)
+	err := marshalEntries(w, indent+"  ", block.Body, opt)
 	if err != nil {
 		return err
 	}
@@ -387,10 +1927,115 @@ func marshalBlock(w io.Writer, indent string, block *Block) error {
 	return nil
 }
 
-func marshalComments(w io.Writer, indent string, comments []string) {
+func marshalCompactNode(w io.Writer, node Node, opt *marshalOptions) error {
+	switch node := node.(type) {
+	case *AST:
+		return marshalCompactEntries(w, node.Entries, opt)
+	case *Block:
+		return marshalCompactBlock(w, node, opt)
+	case *Attribute:
+		return marshalCompactAttribute(w, node, opt)
+	case *Value:
+		marshalInlineValue(w, node, opt)
+		return nil
+	default:
+		return fmt.Errorf("can't marshal node of type %T", node)
+	}
+}
+
+// marshalCompactEntries writes entries on a single line, separated by spaces.
+func marshalCompactEntries(w io.Writer, entries []*Entry, opt *marshalOptions) error {
+	if opt.attributeOrder != nil {
+		entries = orderAttributes(entries, opt.attributeOrder)
+	}
+	entries = uncommentedEntries(entries)
+	for i, entry := range entries {
+		if i > 0 {
+			fmt.Fprint(w, " ")
+		}
+		var err error
+		if entry.Block != nil {
+			err = marshalCompactBlock(w, entry.Block, opt)
+		} else {
+			err = marshalCompactAttribute(w, entry.Attribute, opt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uncommentedEntries returns entries without commented out attributes, which compact output omits.
+func uncommentedEntries(entries []*Entry) []*Entry {
+	out := make([]*Entry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Attribute == nil || !entry.Attribute.Commented {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+func marshalCompactAttribute(w io.Writer, attribute *Attribute, opt *marshalOptions) error {
+	key := attribute.Key
+	if !identifierRe.MatchString(key) {
+		if opt.strictKeys {
+			return fmt.Errorf("attribute key %q is not a valid identifier", key)
+		}
+		key = quoteString(key, opt)
+	}
+	fmt.Fprintf(w, "%s = ", key)
+	marshalInlineValue(w, attribute.Value, opt)
+	return nil
+}
+
+func marshalCompactBlock(w io.Writer, block *Block, opt *marshalOptions) error {
+	fmt.Fprint(w, block.Name)
+	for _, label := range block.Labels {
+		if opt.unquotedLabels && identifierRe.MatchString(label) {
+			fmt.Fprintf(w, " %s", label)
+		} else {
+			fmt.Fprintf(w, " %s", quoteString(label, opt))
+		}
+	}
+	if len(uncommentedEntries(block.Body)) == 0 {
+		fmt.Fprint(w, " {}")
+		return nil
+	}
+	fmt.Fprint(w, " { ")
+	if err := marshalCompactEntries(w, block.Body, opt); err != nil {
+		return err
+	}
+	fmt.Fprint(w, " }")
+	return nil
+}
+
+func marshalComments(w io.Writer, indent string, comments []string, opt *marshalOptions) {
 	for _, comment := range comments {
 		for _, line := range strings.Split(comment, "\n") {
-			fmt.Fprintf(w, "%s// %s\n", indent, line)
+			for _, wrapped := range wrapComment(line, opt.commentWidth-len(indent)-3) {
+				fmt.Fprintf(w, "%s// %s\n", indent, wrapped)
+			}
+		}
+	}
+}
+
+// wrapComment splits a single line of comment text into lines of at most width characters.
+func wrapComment(line string, width int) []string {
+	words := strings.Fields(line)
+	if width <= 0 || len(line) <= width || len(words) == 0 {
+		return []string{line}
+	}
+	lines := []string{}
+	current := words[0]
+	for _, word := range words[1:] {
+		if len(current)+1+len(word) > width {
+			lines = append(lines, current)
+			current = word
+			continue
 		}
+		current += " " + word
 	}
+	return append(lines, current)
 }
@@ -2,9 +2,15 @@ package hcl
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/require"
 )
@@ -172,3 +178,1605 @@ block {
 		})
 	}
 }
+
+func TestMarshalLineEnding(t *testing.T) {
+	type block struct {
+		Map map[string]int `hcl:"map" help:"A map."`
+	}
+	src := &struct {
+		Str   string `hcl:"str" help:"A string."`
+		Block block  `hcl:"block,block"`
+	}{
+		Str:   "str",
+		Block: block{Map: map[string]int{"a": 1}},
+	}
+	data, err := Marshal(src, LineEnding("\r\n"))
+	require.NoError(t, err)
+	require.Equal(t, "// A string.\r\nstr = \"str\"\r\n\r\nblock {\r\n  // A map.\r\n  map = {\r\n    \"a\": 1,\r\n  }\r\n}\r\n", string(data))
+}
+
+func TestMarshalSkipUnsupported(t *testing.T) {
+	src := &struct {
+		Name     string `hcl:"name"`
+		Callback func() `hcl:"callback"`
+	}{
+		Name:     "name",
+		Callback: func() {},
+	}
+	_, err := Marshal(src)
+	require.EqualError(t, err, "unsupported type func()")
+	data, err := Marshal(src, SkipUnsupported(true))
+	require.NoError(t, err)
+	require.Equal(t, "name = \"name\"\n", string(data))
+}
+
+func TestMarshalSkipTag(t *testing.T) {
+	type conf struct {
+		Name    string `hcl:"name"`
+		Skipped string `hcl:"-"`
+		Dash    string `hcl:"-,"`
+	}
+	data, err := Marshal(&conf{Name: "name", Skipped: "skipped", Dash: "dash"})
+	require.NoError(t, err)
+	require.Equal(t, "name = \"name\"\n\"-\" = \"dash\"\n", string(data))
+
+	actual := &conf{}
+	err = UnmarshalAST(hcl(attr("name", str("name")), attr("-", str("dash"))), actual)
+	require.NoError(t, err)
+	require.Equal(t, &conf{Name: "name", Dash: "dash"}, actual)
+
+	err = UnmarshalAST(hcl(attr("name", str("name")), attr("-", str("dash")), attr("Skipped", str("skipped"))), actual)
+	require.EqualError(t, err, `found extra fields "Skipped"`)
+}
+
+func TestMarshalDottedKeys(t *testing.T) {
+	type conf struct {
+		LogLevel string `hcl:"log.level"`
+	}
+	data, err := Marshal(&conf{LogLevel: "debug"})
+	require.NoError(t, err)
+	require.Equal(t, "\"log.level\" = \"debug\"\n", string(data))
+
+	actual := &conf{}
+	err = Unmarshal(data, actual)
+	require.NoError(t, err)
+	require.Equal(t, &conf{LogLevel: "debug"}, actual)
+
+	_, err = Marshal(&conf{LogLevel: "debug"}, StrictKeys(true))
+	require.EqualError(t, err, `attribute key "log.level" is not a valid identifier`)
+}
+
+func TestMarshalLargeIntegers(t *testing.T) {
+	src := &struct {
+		Int  int64  `hcl:"int"`
+		Uint uint64 `hcl:"uint"`
+	}{
+		Int:  -1234567890123456789,
+		Uint: 18446744073709551615,
+	}
+	data, err := Marshal(src)
+	require.NoError(t, err)
+	require.Equal(t, "int = -1234567890123456789\nuint = 18446744073709551615\n", string(data))
+}
+
+func BenchmarkMarshalIntSlice(b *testing.B) {
+	src := &struct {
+		Ints []int `hcl:"ints"`
+	}{}
+	for i := 0; i < 10000; i++ {
+		src.Ints = append(src.Ints, i*1000003)
+	}
+	// BigFloat formats integers via big.Float, as MarshalToAST must, and Strconv directly, as Marshal does.
+	benchmarks := []struct {
+		name        string
+		rawIntegers bool
+	}{
+		{name: "BigFloat"},
+		{name: "Strconv", rawIntegers: true},
+	}
+	for _, bench := range benchmarks {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				opt := newMarshalOptions()
+				opt.rawIntegers = bench.rawIntegers
+				ast, err := marshalToAST(src, false, opt)
+				if err != nil {
+					b.Fatal(err)
+				}
+				_, err = MarshalAST(ast)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestMarshalInvalidUTF8(t *testing.T) {
+	type conf struct {
+		Str string `hcl:"str"`
+	}
+	src := &conf{Str: "a\xffb\xc3é"}
+	data, err := Marshal(src)
+	require.NoError(t, err)
+	require.True(t, utf8.Valid(data))
+	require.Equal(t, "str = \"a\\xffb\\xc3é\"\n", string(data))
+	actual := &conf{}
+	err = Unmarshal(data, actual)
+	require.NoError(t, err)
+	require.Equal(t, src, actual)
+}
+
+func TestMarshalWrapListsOver(t *testing.T) {
+	type conf struct {
+		Short []int    `hcl:"short"`
+		Long  []string `hcl:"long"`
+	}
+	src := &conf{
+		Short: []int{1, 2, 3, 4},
+		Long:  []string{"a", "b", "c", "d", "e"},
+	}
+	data, err := Marshal(src, WrapListsOver(4))
+	require.NoError(t, err)
+	require.Equal(t, `short = [1, 2, 3, 4]
+long = [
+  "a",
+  "b",
+  "c",
+  "d",
+  "e",
+]
+`, string(data))
+	actual := &conf{}
+	err = Unmarshal(data, actual)
+	require.NoError(t, err)
+	require.Equal(t, src, actual)
+}
+
+func TestMarshalUnexportedFields(t *testing.T) {
+	type conf struct {
+		Name    string `hcl:"name"`
+		secret  string
+		tagged  string            `hcl:"tagged"`
+		cache   map[string]string // nolint: structcheck
+		Enabled bool              `hcl:"enabled"`
+	}
+	src := &conf{Name: "name", secret: "secret", tagged: "tagged", Enabled: true}
+	data, err := Marshal(src)
+	require.NoError(t, err)
+	require.Equal(t, "name = \"name\"\nenabled = true\n", string(data))
+
+	actual := &conf{}
+	err = Unmarshal(data, actual)
+	require.NoError(t, err)
+	require.Equal(t, &conf{Name: "name", Enabled: true}, actual)
+
+	_, err = Schema(src)
+	require.NoError(t, err)
+}
+
+func TestMarshalQuoteAll(t *testing.T) {
+	type conf struct {
+		Port    int      `hcl:"port"`
+		Ratio   float64  `hcl:"ratio"`
+		Enabled bool     `hcl:"enabled"`
+		Ports   []uint16 `hcl:"ports"`
+	}
+	src := &conf{Port: 8080, Ratio: 0.5, Enabled: true, Ports: []uint16{80, 443}}
+	data, err := Marshal(src, QuoteAll(true))
+	require.NoError(t, err)
+	require.Equal(t, `port = "8080"
+ratio = "0.5"
+enabled = "true"
+ports = ["80", "443"]
+`, string(data))
+	actual := &conf{}
+	err = Unmarshal(data, actual, QuoteAll(true))
+	require.NoError(t, err)
+	require.Equal(t, src, actual)
+}
+
+func TestMarshalFields(t *testing.T) {
+	type database struct {
+		Host string `hcl:"host"`
+		Port int    `hcl:"port"`
+	}
+	type conf struct {
+		Name     string   `hcl:"name"`
+		Database database `hcl:"database,block"`
+	}
+	src := &conf{Name: "app", Database: database{Host: "localhost", Port: 5432}}
+	data, err := MarshalFields(src, []string{"database.host"})
+	require.NoError(t, err)
+	require.Equal(t, "database {\n  host = \"localhost\"\n}\n", string(data))
+
+	data, err = MarshalFields(src, []string{"name", "database"})
+	require.NoError(t, err)
+	require.Equal(t, "name = \"app\"\n\ndatabase {\n  host = \"localhost\"\n  port = 5432\n}\n", string(data))
+
+	_, err = MarshalFields(src, []string{"database.user"})
+	require.EqualError(t, err, `no attribute or block matches "database.user"`)
+}
+
+func TestMarshalMapSeparator(t *testing.T) {
+	type conf struct {
+		Map  map[string]int   `hcl:"map"`
+		List []map[string]int `hcl:"list"`
+	}
+	src := &conf{
+		Map:  map[string]int{"a": 1},
+		List: []map[string]int{{"b": 2}},
+	}
+	for _, test := range []struct {
+		sep      string
+		expected string
+	}{
+		{":", "map = {\n  \"a\": 1,\n}\nlist = [{\"b\": 2}]\n"},
+		{"=", "map = {\n  \"a\" = 1,\n}\nlist = [{\"b\" = 2}]\n"},
+	} {
+		t.Run(test.sep, func(t *testing.T) {
+			data, err := Marshal(src, MapSeparator(test.sep))
+			require.NoError(t, err)
+			require.Equal(t, test.expected, string(data))
+			actual := &conf{}
+			err = Unmarshal(data, actual)
+			require.NoError(t, err)
+			require.Equal(t, src, actual)
+		})
+	}
+}
+
+func TestMarshalTopLevelMapAndSlice(t *testing.T) {
+	type service struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	services := map[string]service{
+		"web": {Name: "frontend", Port: 80},
+		"api": {Name: "backend", Port: 8080},
+	}
+	data, err := Marshal(&services)
+	require.NoError(t, err)
+	require.Equal(t, `api "backend" {
+  port = 8080
+}
+
+web "frontend" {
+  port = 80
+}
+`, string(data))
+
+	attrs := map[string]int{"b": 2, "a": 1}
+	data, err = Marshal(&attrs)
+	require.NoError(t, err)
+	require.Equal(t, "a = 1\nb = 2\n", string(data))
+
+	type fragment struct {
+		Key string `hcl:"key"`
+	}
+	fragments := []interface{}{&fragment{Key: "one"}, map[string]string{"other": "two"}}
+	data, err = Marshal(&fragments)
+	require.NoError(t, err)
+	require.Equal(t, "key = \"one\"\nother = \"two\"\n", string(data))
+
+	str := "str"
+	_, err = Marshal(&str)
+	require.EqualError(t, err, "expected a pointer to a struct, map or slice, not string")
+}
+
+func TestMarshalWithSharedOptions(t *testing.T) {
+	type config struct {
+		Name  string   `hcl:"name"`
+		Ports []int    `hcl:"ports"`
+		Tags  []string `hcl:"tags"`
+	}
+	opts := NewOptions(LineEnding("\r\n"), WrapListsOver(1))
+	expected := "name = \"web\"\r\nports = [\r\n  80,\r\n  443,\r\n]\r\ntags = [\"a\"]\r\n"
+	wg := sync.WaitGroup{}
+	errs := make(chan error, 16)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data, err := MarshalWith(opts, &config{Name: "web", Ports: []int{80, 443}, Tags: []string{"a"}})
+			if err == nil && string(data) != expected {
+				err = fmt.Errorf("unexpected output %q", data)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func TestNewOptionsCopiesMaps(t *testing.T) {
+	type config struct {
+		Env map[string]string `hcl:"env"`
+	}
+	order := map[string][]string{"env": {"b", "a"}}
+	opts := NewOptions(MapKeyOrder(order))
+	order["env"][0] = "a"
+	order["env"][1] = "b"
+	data, err := MarshalWith(opts, &config{Env: map[string]string{"a": "1", "b": "2"}})
+	require.NoError(t, err)
+	require.Equal(t, "env = {\n  \"b\": \"2\",\n  \"a\": \"1\",\n}\n", string(data))
+}
+
+func TestMarshalEscapesTemplates(t *testing.T) {
+	type config struct {
+		Str  string            `hcl:"str"`
+		List []string          `hcl:"list"`
+		Map  map[string]string `hcl:"map"`
+	}
+	in := &config{
+		Str:  "${not_a_var} and %{if x}",
+		List: []string{"$${already}"},
+		Map:  map[string]string{"key": "${v}"},
+	}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `str = "$${not_a_var} and %%{if x}"
+list = ["$$${already}"]
+map = {
+  "key": "$${v}",
+}
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestMarshalMapAsBlock(t *testing.T) {
+	type config struct {
+		Name   string            `hcl:"name"`
+		Labels map[string]string `hcl:"labels,block"`
+		Env    map[string]string `hcl:"env"`
+		Extra  map[string]string `hcl:"extra,block"`
+	}
+	in := &config{
+		Name:   "web",
+		Labels: map[string]string{"tier": "frontend", "app.kubernetes.io/name": "web"},
+		Env:    map[string]string{"DEBUG": "1"},
+	}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `name = "web"
+
+labels {
+  "app.kubernetes.io/name" = "web"
+  tier = "frontend"
+}
+
+env = {
+  "DEBUG": "1",
+}
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestMarshalCommentWidth(t *testing.T) {
+	type block struct {
+		Value string `hcl:"value" help:"A nested comment that is also long enough to need wrapping."`
+	}
+	type config struct {
+		Name  string `hcl:"name" help:"The name of the service, which is used to derive hostnames and log prefixes.\nSee the docs."`
+		Block block  `hcl:"block,block"`
+	}
+	data, err := Marshal(&config{Name: "web"}, CommentWidth(40))
+	require.NoError(t, err)
+	require.Equal(t, `// The name of the service, which is
+// used to derive hostnames and log
+// prefixes.
+// See the docs.
+name = "web"
+
+block {
+  // A nested comment that is also long
+  // enough to need wrapping.
+  value = ""
+}
+`, string(data))
+	for _, line := range strings.Split(string(data), "\n") {
+		require.True(t, len(line) <= 40, line)
+	}
+}
+
+func TestMarshalDigitGrouping(t *testing.T) {
+	type config struct {
+		Big   int     `hcl:"big"`
+		Small int     `hcl:"small"`
+		Float float64 `hcl:"float"`
+		List  []int   `hcl:"list"`
+	}
+	in := &config{Big: 1000000, Small: 999, Float: 1234.5, List: []int{1000, 10}}
+	data, err := Marshal(in, DigitGrouping(true))
+	require.NoError(t, err)
+	require.Equal(t, `big = 1_000_000
+small = 999
+float = 1234.5
+list = [1_000, 10]
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestMarshalSortLists(t *testing.T) {
+	type config struct {
+		Tags  []string `hcl:"tags"`
+		Ports []int    `hcl:"ports"`
+	}
+	in := &config{Tags: []string{"web", "api", "db"}, Ports: []int{443, 80}}
+	data, err := Marshal(in, SortLists(true))
+	require.NoError(t, err)
+	require.Equal(t, "tags = [\"api\", \"db\", \"web\"]\nports = [443, 80]\n", string(data))
+	require.Equal(t, []string{"web", "api", "db"}, in.Tags)
+
+	data, err = Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, "tags = [\"web\", \"api\", \"db\"]\nports = [443, 80]\n", string(data))
+}
+
+type generatedEntries struct {
+	Name string
+	Port int
+}
+
+func (g *generatedEntries) MarshalHCLEntries() ([]*Entry, error) {
+	port := float64(g.Port)
+	return []*Entry{
+		{Attribute: &Attribute{Key: "name", Value: &Value{Str: &g.Name}}},
+		{Attribute: &Attribute{Key: "port", Value: &Value{Number: big.NewFloat(port)}}},
+	}, nil
+}
+
+func TestMarshalHCLEntries(t *testing.T) {
+	type config struct {
+		Service generatedEntries `hcl:"service,block"`
+	}
+	data, err := Marshal(&config{Service: generatedEntries{Name: "web", Port: 80}})
+	require.NoError(t, err)
+	require.Equal(t, `service {
+  name = "web"
+  port = 80
+}
+`, string(data))
+
+	data, err = Marshal(&generatedEntries{Name: "api", Port: 8080})
+	require.NoError(t, err)
+	require.Equal(t, "name = \"api\"\nport = 8080\n", string(data))
+}
+
+func TestMarshalMapOfStructs(t *testing.T) {
+	type Point struct {
+		X int `hcl:"x"`
+		Y int `hcl:"y"`
+	}
+	type config struct {
+		Points map[string]Point `hcl:"points"`
+	}
+	in := &config{Points: map[string]Point{"origin": {}, "corner": {X: 10, Y: 20}}}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `points = {
+  "corner": {
+    "x": 10,
+    "y": 20,
+  },
+  "origin": {
+    "x": 0,
+    "y": 0,
+  },
+}
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestMarshalMapOfAnonymousStructs(t *testing.T) {
+	type config struct {
+		Limits map[string]struct {
+			CPU    int
+			Memory string `hcl:"memory"`
+		} `hcl:"limits"`
+	}
+	in := &config{}
+	in.Limits = map[string]struct {
+		CPU    int
+		Memory string `hcl:"memory"`
+	}{
+		"small": {CPU: 1, Memory: "512M"},
+		"large": {CPU: 8, Memory: "16G"},
+	}
+	data, err := Marshal(in, UntaggedKeyCase(KeyCaseLower))
+	require.NoError(t, err)
+	require.Equal(t, `limits = {
+  "large": {
+    "cpu": 8,
+    "memory": "16G",
+  },
+  "small": {
+    "cpu": 1,
+    "memory": "512M",
+  },
+}
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out, UntaggedKeyCase(KeyCaseLower))
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestMarshalMapOfPointers(t *testing.T) {
+	type Service struct {
+		Port int `hcl:"port"`
+	}
+	type config struct {
+		Services map[string]*Service `hcl:"services"`
+	}
+	in := &config{Services: map[string]*Service{"web": {Port: 80}, "disabled": nil}}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `services = {
+  "disabled": null,
+  "web": {
+    "port": 80,
+  },
+}
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	// Nil entries are omitted from top-level maps, where struct values are blocks.
+	data, err = Marshal(&map[string]*Service{"web": {Port: 80}, "disabled": nil})
+	require.NoError(t, err)
+	require.Equal(t, `web {
+  port = 80
+}
+`, string(data))
+}
+
+func TestMarshalStructValues(t *testing.T) {
+	type Point struct {
+		X int `hcl:"x"`
+		Y int `hcl:"y"`
+	}
+	type config struct {
+		Path   []Point     `hcl:"path"`
+		Any    interface{} `hcl:"any,optional"`
+		Origin *Point      `hcl:"origin"`
+		Centre Point       `hcl:"centre"`
+	}
+	in := &config{
+		Path:   []Point{{X: 1, Y: 2}, {X: 3, Y: 4}},
+		Any:    Point{X: 5, Y: 6},
+		Centre: Point{X: 7, Y: 8},
+	}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `path = [{"x": 1, "y": 2}, {"x": 3, "y": 4}]
+any = {
+  "x": 5,
+  "y": 6,
+}
+origin = null
+centre = {
+  "x": 7,
+  "y": 8,
+}
+`, string(data))
+
+	out := &config{}
+	err = Unmarshal([]byte(`
+path = [{"x": 1, "y": 2}, {"x": 3, "y": 4}]
+origin = null
+centre = {
+  "x": 7,
+  "y": 8,
+}
+`), out)
+	require.NoError(t, err)
+	require.Equal(t, in.Path, out.Path)
+	require.Equal(t, in.Centre, out.Centre)
+}
+
+func TestMarshalUntaggedKeyCase(t *testing.T) {
+	type config struct {
+		MaxRetries int
+		Timeout    int    `hcl:",optional"`
+		Name       string `hcl:"Name"`
+	}
+	tests := []struct {
+		keyCase  KeyCase
+		expected string
+	}{
+		{KeyCaseExact, "MaxRetries = 3\nTimeout = 10\nName = \"web\"\n"},
+		{KeyCaseLower, "maxretries = 3\ntimeout = 10\nName = \"web\"\n"},
+		{KeyCaseSnake, "max_retries = 3\ntimeout = 10\nName = \"web\"\n"},
+	}
+	for _, test := range tests {
+		in := &config{MaxRetries: 3, Timeout: 10, Name: "web"}
+		data, err := Marshal(in, UntaggedKeyCase(test.keyCase))
+		require.NoError(t, err)
+		require.Equal(t, test.expected, string(data))
+		out := &config{}
+		err = Unmarshal(data, out, UntaggedKeyCase(test.keyCase))
+		require.NoError(t, err)
+		require.Equal(t, in, out)
+	}
+}
+
+func TestMarshalRadixTag(t *testing.T) {
+	type config struct {
+		Mode  uint32 `hcl:"mode,radix=octal"`
+		Mask  int    `hcl:"mask,radix=hex"`
+		Flags []int  `hcl:"flags,radix=binary"`
+		Delta int    `hcl:"delta,radix=hex"`
+	}
+	in := &config{Mode: 0755, Mask: 0xff00, Flags: []int{1, 6}, Delta: -16}
+	expected := `mode = 0o755
+mask = 0xff00
+flags = [0b1, 0b110]
+delta = -0x10
+`
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(data))
+	data, err = Marshal(in, DigitGrouping(true))
+	require.NoError(t, err)
+	require.Equal(t, expected, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	type invalid struct {
+		Name string `hcl:"name,label,radix=hex"`
+	}
+	_, err = Marshal(&invalid{})
+	require.EqualError(t, err, `conflicting HCL tag options "label" and "radix=hex" on github.com/alecthomas/hcl.invalid.Name`)
+}
+
+func TestMarshalCompactEmptyBlocks(t *testing.T) {
+	type empty struct{}
+	type config struct {
+		Name   string `hcl:"name"`
+		Empty  empty  `hcl:"empty,block"`
+		Labels struct {
+			Name string `hcl:"name,label"`
+		} `hcl:"labelled,block"`
+	}
+	in := &config{Name: "web"}
+	in.Labels.Name = "x"
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `name = "web"
+
+empty {
+}
+
+labelled "x" {
+}
+`, string(data))
+
+	data, err = Marshal(in, CompactEmptyBlocks(true))
+	require.NoError(t, err)
+	require.Equal(t, `name = "web"
+
+empty {}
+
+labelled "x" {}
+`, string(data))
+}
+
+func TestJSONNumber(t *testing.T) {
+	type config struct {
+		Pi    json.Number   `hcl:"pi"`
+		Big   json.Number   `hcl:"big"`
+		List  []json.Number `hcl:"list"`
+		Empty json.Number   `hcl:"empty"`
+	}
+	in := &config{
+		Pi:   "3.14159265358979323846264338327950288",
+		Big:  "123456789012345678901234567890",
+		List: []json.Number{"1", "0.1", "-2.5e-10"},
+	}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `pi = 3.14159265358979323846264338327950288
+big = 123456789012345678901234567890
+list = [1, 0.1, -2.5e-10]
+empty = 0
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	in.Empty = "0"
+	require.Equal(t, in, out)
+
+	err = Unmarshal([]byte("pi = 1.10\nbig = 123456789012345678901234567890\nlist = [1e3, 0x10]\nempty = 0\n"), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{Pi: "1.10", Big: in.Big, List: []json.Number{"1e3", "16"}, Empty: "0"}, out)
+	data, err = Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, "pi = 1.10\nbig = 123456789012345678901234567890\nlist = [1e3, 16]\nempty = 0\n", string(data))
+
+	_, err = Marshal(&config{Pi: "pie"})
+	require.Error(t, err)
+}
+
+func TestMarshalAttributeOrder(t *testing.T) {
+	type service struct {
+		Name string `hcl:"name"`
+		Host string `hcl:"host"`
+		Port int    `hcl:"port"`
+	}
+	type config struct {
+		Name    string  `hcl:"name"`
+		Host    string  `hcl:"host"`
+		Port    int     `hcl:"port"`
+		Service service `hcl:"service,block"`
+		Debug   bool    `hcl:"debug"`
+	}
+	in := &config{Name: "a", Host: "b", Port: 1, Service: service{Name: "c", Host: "d", Port: 2}}
+	data, err := Marshal(in, AttributeOrder([]string{"port", "host", "missing"}))
+	require.NoError(t, err)
+	require.Equal(t, `port = 1
+host = "b"
+name = "a"
+
+service {
+  port = 2
+  host = "d"
+  name = "c"
+}
+
+debug = false
+`, string(data))
+}
+
+func TestMarshalMaxDepth(t *testing.T) {
+	type node struct {
+		Name     string  `hcl:"name"`
+		Children []*node `hcl:"child,block"`
+	}
+	tree := &node{Name: "root", Children: []*node{{Name: "a", Children: []*node{{Name: "b"}}}}}
+	_, err := Marshal(tree, MaxDepth(3))
+	require.NoError(t, err)
+	_, err = Marshal(tree, MaxDepth(2))
+	require.EqualError(t, err, `maximum depth of 2 exceeded at "child[0].child[0]"`)
+
+	type cyclic struct {
+		Name string  `hcl:"name"`
+		Next *cyclic `hcl:"next"`
+	}
+	loop := &cyclic{Name: "loop"}
+	loop.Next = loop
+	_, err = Marshal(loop, MaxDepth(4))
+	require.EqualError(t, err, `maximum depth of 4 exceeded at "next.next.next.next"`)
+}
+
+func TestMarshalTrailingNewline(t *testing.T) {
+	type block struct {
+		Name string `hcl:"name"`
+	}
+	type config struct {
+		Name  string `hcl:"name"`
+		Block *block `hcl:"block,block"`
+	}
+	tests := []struct {
+		name     string
+		value    *config
+		options  []MarshalOption
+		expected string
+	}{
+		{"DefaultAttribute", &config{Name: "a"}, nil, "name = \"a\"\n"},
+		{"DefaultBlock", &config{Block: &block{}}, nil, "name = \"\"\n\nblock {\n  name = \"\"\n}\n"},
+		{"Strip", &config{Name: "a"}, []MarshalOption{TrailingNewline(false)}, "name = \"a\""},
+		{"StripBlock", &config{Block: &block{}}, []MarshalOption{TrailingNewline(false)}, "name = \"\"\n\nblock {\n  name = \"\"\n}"},
+		{"Keep", &config{Name: "a"}, []MarshalOption{TrailingNewline(true)}, "name = \"a\"\n"},
+		{"StripCRLF", &config{Name: "a"}, []MarshalOption{TrailingNewline(false), LineEnding("\r\n")}, "name = \"a\""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := Marshal(test.value, test.options...)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, string(data))
+		})
+	}
+
+	value := &Value{Str: strp("a")}
+	data, err := MarshalAST(value)
+	require.NoError(t, err)
+	require.Equal(t, `"a"`, string(data))
+	data, err = MarshalAST(value, TrailingNewline(true))
+	require.NoError(t, err)
+	require.Equal(t, "\"a\"\n", string(data))
+}
+
+func TestMarshalMapOfSlices(t *testing.T) {
+	type config struct {
+		Groups map[string][]string `hcl:"groups"`
+	}
+	tests := []struct {
+		name     string
+		value    map[string][]string
+		expected string
+		decoded  map[string][]string
+	}{
+		{"Populated", map[string][]string{"admin": {"alice", "bob"}, "ops": {"carol"}},
+			"groups = {\n  \"admin\": [\"alice\", \"bob\"],\n  \"ops\": [\"carol\"],\n}\n",
+			map[string][]string{"admin": {"alice", "bob"}, "ops": {"carol"}}},
+		{"EmptyInner", map[string][]string{"admin": {}},
+			"groups = {\n  \"admin\": [],\n}\n",
+			map[string][]string{"admin": {}}},
+		{"NilInner", map[string][]string{"admin": nil},
+			"groups = {\n  \"admin\": [],\n}\n",
+			map[string][]string{"admin": {}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := Marshal(&config{Groups: test.value})
+			require.NoError(t, err)
+			require.Equal(t, test.expected, string(data))
+			out := &config{}
+			err = Unmarshal(data, out)
+			require.NoError(t, err)
+			require.Equal(t, test.decoded, out.Groups)
+		})
+	}
+}
+
+func TestMarshalUnquotedLabels(t *testing.T) {
+	type variable struct {
+		Name    string `hcl:"name,label"`
+		Default string `hcl:"default"`
+	}
+	type config struct {
+		Variables []variable `hcl:"variable,block"`
+	}
+	in := &config{Variables: []variable{{Name: "foo", Default: "a"}, {Name: "not an ident", Default: "b"}}}
+	data, err := Marshal(in, UnquotedLabels(true))
+	require.NoError(t, err)
+	require.Equal(t, `variable foo {
+  default = "a"
+}
+
+variable "not an ident" {
+  default = "b"
+}
+`, string(data))
+
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestMarshalMaxLineLength(t *testing.T) {
+	type service struct {
+		Name    string              `hcl:"name,label"`
+		Ports   []int               `hcl:"ports"`
+		Hosts   []string            `hcl:"hosts"`
+		Matrix  [][]string          `hcl:"matrix"`
+		Labels  map[string][]string `hcl:"labels"`
+		Comment string              `hcl:"comment"`
+	}
+	type config struct {
+		Services []service `hcl:"service,block"`
+	}
+	in := &config{Services: []service{{
+		Name:  "web",
+		Ports: []int{80, 443},
+		Hosts: []string{"alpha.example.com", "beta.example.com", "gamma.example.com", "delta.example.com"},
+		Matrix: [][]string{
+			{"short"},
+			{"a-rather-long-element-name", "another-rather-long-element-name", "and-a-third"},
+		},
+		Labels: map[string][]string{
+			"team":   {"platform"},
+			"owners": {"alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com"},
+		},
+		Comment: "a single string token that is longer than eighty columns is never split across lines",
+	}}}
+	data, err := Marshal(in, MaxLineLength(80))
+	require.NoError(t, err)
+	require.Equal(t, `service "web" {
+  ports = [80, 443]
+  hosts = [
+    "alpha.example.com",
+    "beta.example.com",
+    "gamma.example.com",
+    "delta.example.com",
+  ]
+  matrix = [
+    ["short"],
+    [
+      "a-rather-long-element-name",
+      "another-rather-long-element-name",
+      "and-a-third",
+    ],
+  ]
+  labels = {
+    "owners": [
+      "alice@example.com",
+      "bob@example.com",
+      "carol@example.com",
+      "dave@example.com",
+    ],
+    "team": ["platform"],
+  }
+  comment = "a single string token that is longer than eighty columns is never split across lines"
+}
+`, string(data))
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.Contains(line, "comment") {
+			require.LessOrEqual(t, len(line), 80, line)
+		}
+	}
+
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestMarshalMultilineTag(t *testing.T) {
+	type config struct {
+		Script  string  `hcl:"script,multiline"`
+		Notes   *string `hcl:"notes,multiline,optional"`
+		Command string  `hcl:"command"`
+	}
+	notes := "EOF\nis taken"
+	in := &config{Script: "echo hello", Notes: &notes, Command: "run"}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `script = <<EOF
+echo hello
+EOF
+notes = <<EOF1
+EOF
+is taken
+EOF1
+command = "run"
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	type invalid struct {
+		Count int `hcl:"count,multiline"`
+	}
+	_, err = Marshal(&invalid{})
+	require.EqualError(t, err, "multiline field github.com/alecthomas/hcl.invalid.Count must be a string but is int")
+}
+
+func TestMarshalSize(t *testing.T) {
+	type block struct {
+		Name  string   `hcl:"name,label"`
+		Hosts []string `hcl:"hosts"`
+	}
+	type config struct {
+		Title  string  `hcl:"title" help:"The title."`
+		Blocks []block `hcl:"block,block"`
+	}
+	in := &config{Title: "héllo", Blocks: []block{{Name: "a", Hosts: []string{"x", "y"}}, {Name: "b"}}}
+	for _, options := range [][]MarshalOption{nil, {LineEnding("\r\n")}, {TrailingNewline(false)}, {WrapListsOver(1)}} {
+		data, err := Marshal(in, options...)
+		require.NoError(t, err)
+		size, err := MarshalSize(in, options...)
+		require.NoError(t, err)
+		require.Equal(t, len(data), size)
+	}
+
+	_, err := MarshalSize(config{})
+	require.Error(t, err)
+}
+
+func TestMarshalGroupTag(t *testing.T) {
+	type server struct {
+		Name    string `hcl:"name"`
+		Host    string `hcl:"host,group=network"`
+		Timeout int    `hcl:"timeout"`
+		Port    int    `hcl:"port,group=network"`
+		Debug   bool   `hcl:"debug,group=logging"`
+		Level   string `hcl:"level,group=logging"`
+	}
+	type config struct {
+		Server server `hcl:"server,block"`
+	}
+	in := &config{Server: server{Name: "web", Host: "localhost", Timeout: 30, Port: 8080, Level: "info"}}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `server {
+  name = "web"
+  timeout = 30
+
+  host = "localhost"
+  port = 8080
+
+  debug = false
+  level = "info"
+}
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	type invalid struct {
+		Block server `hcl:"block,block,group=x"`
+	}
+	_, err = Marshal(&invalid{})
+	require.EqualError(t, err, `conflicting HCL tag options "group=x" and "block" on github.com/alecthomas/hcl.invalid.Block`)
+}
+
+type identLevel string
+
+func TestMarshalIdentTag(t *testing.T) {
+	type config struct {
+		Level   identLevel  `hcl:"level,ident"`
+		Backup  *identLevel `hcl:"backup,ident,optional"`
+		Message string      `hcl:"message"`
+	}
+	backup := identLevel("warn-only")
+	in := &config{Level: "debug", Backup: &backup, Message: "hello"}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `level = debug
+backup = warn-only
+message = "hello"
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	_, err = Marshal(&config{Level: "not an ident"})
+	require.EqualError(t, err, `value "not an ident" of Level is not a valid identifier`)
+	_, err = Marshal(&config{Level: "true"})
+	require.EqualError(t, err, `value "true" of Level is not a valid identifier`)
+}
+
+type stringerAddress struct {
+	host string
+	port int
+}
+
+func (a stringerAddress) String() string { return fmt.Sprintf("%s:%d", a.host, a.port) }
+
+func TestMarshalStringers(t *testing.T) {
+	type config struct {
+		Err     error        `hcl:"err"`
+		NilErr  error        `hcl:"nil_err"`
+		Address fmt.Stringer `hcl:"address"`
+		Any     interface{}  `hcl:"any"`
+		Name    string       `hcl:"name"`
+	}
+	in := &config{
+		Err:     errors.New("connection refused"),
+		Address: stringerAddress{host: "localhost", port: 8080},
+		Any:     stringerAddress{host: "example.com", port: 443},
+		Name:    "web",
+	}
+	data, err := Marshal(in, MarshalStringers(true))
+	require.NoError(t, err)
+	require.Equal(t, `err = "connection refused"
+nil_err = null
+address = "localhost:8080"
+any = "example.com:443"
+name = "web"
+`, string(data))
+}
+
+func TestMarshalOmitZero(t *testing.T) {
+	type limits struct {
+		CPU int `hcl:"cpu"`
+	}
+	type config struct {
+		Name    string     `hcl:"name"`
+		Port    int        `hcl:"port,omitzero"`
+		Created time.Time  `hcl:"created,omitzero"`
+		Expires *time.Time `hcl:"expires,omitzero"`
+		Limits  limits     `hcl:"limits,block,omitzero"`
+		Point   limits     `hcl:"point,omitzero"`
+	}
+	data, err := Marshal(&config{Name: "web", Expires: &time.Time{}})
+	require.NoError(t, err)
+	require.Equal(t, "name = \"web\"\n", string(data))
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	data, err = Marshal(&config{Name: "web", Port: 80, Created: created, Limits: limits{CPU: 2}, Point: limits{CPU: 1}})
+	require.NoError(t, err)
+	require.Equal(t, `name = "web"
+port = 80
+created = "2020-01-02T03:04:05Z"
+
+limits {
+  cpu = 2
+}
+
+point = {
+  "cpu": 1,
+}
+`, string(data))
+
+	// Unlike optional, omitzero does not make fields optional in the schema.
+	type schemaConfig struct {
+		Port int `hcl:"port,omitzero"`
+	}
+	schema, err := Schema(&schemaConfig{})
+	require.NoError(t, err)
+	require.False(t, schema.Entries[0].Attribute.Optional)
+}
+
+func TestMarshalCompact(t *testing.T) {
+	type service struct {
+		Name  string            `hcl:"name,label"`
+		Ports []int             `hcl:"ports"`
+		Tags  map[string]string `hcl:"tags"`
+		Empty *struct{}         `hcl:"empty,block"`
+	}
+	type config struct {
+		Version  string     `hcl:"version" help:"Config version."`
+		Script   string     `hcl:"script,multiline"`
+		Services []*service `hcl:"service,block"`
+	}
+	in := &config{
+		Version: "1.0",
+		Script:  "echo hello\necho world\n",
+		Services: []*service{
+			{Name: "web", Ports: []int{80, 443}, Tags: map[string]string{"env": "prod"}, Empty: &struct{}{}},
+			{Name: "db", Ports: []int{5432}, Tags: map[string]string{}},
+		},
+	}
+	data, err := Marshal(in, Compact(true))
+	require.NoError(t, err)
+	require.Equal(t, `version = "1.0" script = "echo hello\necho world\n" `+
+		`service "web" { ports = [80, 443] tags = {"env": "prod"} empty {} } `+
+		`service "db" { ports = [5432] tags = {} }`, string(data))
+
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	data, err = Marshal(in, Compact(true), TrailingNewline(true))
+	require.NoError(t, err)
+	require.Equal(t, 1, strings.Count(string(data), "\n"))
+	require.True(t, strings.HasSuffix(string(data), "\n"))
+}
+
+func TestMarshalMapKeyOrder(t *testing.T) {
+	type service struct {
+		Name string            `hcl:"name,label"`
+		Env  map[string]string `hcl:"env"`
+	}
+	type config struct {
+		Tags     map[string]int `hcl:"tags"`
+		Services []service      `hcl:"service,block"`
+	}
+	in := &config{
+		Tags: map[string]int{"a": 1, "b": 2, "c": 3, "d": 4},
+		Services: []service{
+			{Name: "web", Env: map[string]string{"HOME": "/", "PATH": "/bin", "USER": "web"}},
+		},
+	}
+	data, err := Marshal(in, MapKeyOrder(map[string][]string{
+		"tags":           {"c", "a", "missing"},
+		"service[0].env": {"USER", "PATH"},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, `tags = {
+  "c": 3,
+  "a": 1,
+  "b": 2,
+  "d": 4,
+}
+
+service "web" {
+  env = {
+    "USER": "web",
+    "PATH": "/bin",
+    "HOME": "/",
+  }
+}
+`, string(data))
+
+	data, err = Marshal(&map[string]int{"x": 1, "y": 2, "z": 3}, MapKeyOrder(map[string][]string{"": {"z"}}))
+	require.NoError(t, err)
+	require.Equal(t, "z = 3\nx = 1\ny = 2\n", string(data))
+}
+
+func TestMarshalToWriter(t *testing.T) {
+	type block struct {
+		Name  string   `hcl:"name,label"`
+		Hosts []string `hcl:"hosts"`
+	}
+	type config struct {
+		Title  string  `hcl:"title" help:"The title."`
+		Blocks []block `hcl:"block,block"`
+	}
+	in := &config{Title: "hello", Blocks: []block{{Name: "a", Hosts: []string{"x", "y"}}, {Name: "b"}}}
+	for _, options := range [][]MarshalOption{nil, {LineEnding("\r\n")}, {TrailingNewline(false)}, {Compact(true)}} {
+		expected, err := Marshal(in, options...)
+		require.NoError(t, err)
+		w := &strings.Builder{}
+		err = MarshalToWriter(w, in, options...)
+		require.NoError(t, err)
+		require.Equal(t, string(expected), w.String())
+	}
+
+	err := MarshalToWriter(&strings.Builder{}, config{})
+	require.Error(t, err)
+}
+
+func TestMarshalLinesTag(t *testing.T) {
+	type config struct {
+		Script []string `hcl:"script,lines"`
+		Empty  []string `hcl:"empty,lines,optional"`
+	}
+	in := &config{Script: []string{"set -e", "", "echo hello"}}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `script = <<EOF
+set -e
+
+echo hello
+EOF
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	in = &config{Script: []string{"echo $${HOME}", "printf %%{x}", "echo ${HOME}"}}
+	data, err = Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, "script = <<EOF\necho $${HOME}\nprintf %%{x}\necho ${HOME}\nEOF\n", string(data))
+	out = &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	err = Unmarshal([]byte(`script = "a\nb"`+"\n"+`empty = ["c"]`), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{Script: []string{"a", "b"}, Empty: []string{"c"}}, out)
+
+	_, err = Marshal(&config{Script: []string{"a\nb"}})
+	require.EqualError(t, err, `line "a\nb" of Script contains a newline`)
+
+	type invalid struct {
+		Script string `hcl:"script,lines"`
+	}
+	_, err = Marshal(&invalid{})
+	require.EqualError(t, err, "lines field github.com/alecthomas/hcl.invalid.Script must be a string slice but is string")
+}
+
+func TestMarshalShortestFloats(t *testing.T) {
+	type config struct {
+		F64   float64    `hcl:"f64"`
+		F32   float32    `hcl:"f32"`
+		List  []float32  `hcl:"list"`
+		Small float64    `hcl:"small"`
+		Big   *big.Float `hcl:"big"`
+	}
+	in := &config{
+		F64:   0.1,
+		F32:   0.1,
+		List:  []float32{1.1, 2.5, 1e-7},
+		Small: 1.0 / 3,
+		Big:   new(big.Float).SetPrec(100).Quo(big.NewFloat(1), big.NewFloat(3).SetPrec(100)),
+	}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `f64 = 0.1
+f32 = 0.1
+list = [1.1, 2.5, 1e-07]
+small = 0.3333333333333333
+big = 0.3333333333333333333333333333335
+`, string(data))
+
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in.F64, out.F64)
+	require.Equal(t, in.F32, out.F32)
+	require.Equal(t, in.List, out.List)
+	require.Equal(t, in.Small, out.Small)
+}
+
+func TestMarshalHeader(t *testing.T) {
+	type block struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type config struct {
+		Server block `hcl:"server,block" help:"The server."`
+	}
+	data, err := Marshal(&config{Server: block{Name: "web", Port: 80}},
+		WithHeader("Code generated by hclgen. DO NOT EDIT.\n"))
+	require.NoError(t, err)
+	require.Equal(t, `// Code generated by hclgen. DO NOT EDIT.
+
+// The server.
+server "web" {
+  port = 80
+}
+`, string(data))
+
+	ast, err := ParseString(`a = 1`)
+	require.NoError(t, err)
+	ast.LeadingComments = []string{"Generated file.", "Edit the source instead."}
+	data, err = MarshalAST(ast, WithHeader("DO NOT EDIT."))
+	require.NoError(t, err)
+	require.Equal(t, `// DO NOT EDIT.
+// Generated file.
+// Edit the source instead.
+
+a = 1
+`, string(data))
+
+	// Leading comments are attached to the first entry when parsed.
+	ast, err = ParseBytes(data)
+	require.NoError(t, err)
+	require.Empty(t, ast.LeadingComments)
+	require.Equal(t, []string{"DO NOT EDIT.", "Generated file.", "Edit the source instead."}, ast.Entries[0].Attribute.Comments)
+}
+
+func TestMarshalAnnotateChanges(t *testing.T) {
+	type server struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+		Host string `hcl:"host"`
+	}
+	type config struct {
+		Version string   `hcl:"version"`
+		Tags    []string `hcl:"tags"`
+		Servers []server `hcl:"server,block"`
+	}
+	baseline, err := ParseString(`
+version = "1"
+tags = ["a"]
+server "web" {
+  port = 8080
+  host = "localhost"
+}
+server "api" {
+  port = 9090
+}
+`)
+	require.NoError(t, err)
+	data, err := Marshal(&config{
+		Version: "1",
+		Tags:    []string{"a", "b"},
+		Servers: []server{
+			{Name: "web", Port: 80, Host: "localhost"},
+			{Name: "api", Port: 9090, Host: "example.com"},
+		},
+	}, AnnotateChanges(baseline))
+	require.NoError(t, err)
+	require.Equal(t, `version = "1"
+tags = ["a", "b"] // was ["a"]
+
+server "web" {
+  port = 80 // was 8080
+  host = "localhost"
+}
+
+server "api" {
+  port = 9090
+  host = "example.com"
+}
+`, string(data))
+
+	// Numerically equal values are unchanged, however they were written.
+	type numbers struct {
+		F float64 `hcl:"f"`
+		N int     `hcl:"n"`
+		E float64 `hcl:"e"`
+	}
+	baseline, err = ParseString(`
+f = 1.0
+n = 0x10
+e = 2.5
+`)
+	require.NoError(t, err)
+	data, err = Marshal(&numbers{F: 1, N: 16, E: 2.75}, AnnotateChanges(baseline))
+	require.NoError(t, err)
+	require.Equal(t, `f = 1
+n = 16
+e = 2.75 // was 2.5
+`, string(data))
+}
+
+func TestMarshalMapOfInterfaceStructs(t *testing.T) {
+	type service struct {
+		Port  int      `hcl:"port"`
+		Hosts []string `hcl:"hosts"`
+	}
+	type config struct {
+		Settings map[string]interface{} `hcl:"settings"`
+	}
+	in := &config{Settings: map[string]interface{}{
+		"name": "web",
+		"main": service{Port: 80, Hosts: []string{"a"}},
+		"alt":  &service{Port: 8080},
+	}}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `settings = {
+  "alt": {
+    "port": 8080,
+    "hosts": [],
+  },
+  "main": {
+    "port": 80,
+    "hosts": ["a"],
+  },
+  "name": "web",
+}
+`, string(data))
+
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, &config{Settings: map[string]interface{}{
+		"name": "web",
+		"main": map[string]interface{}{"port": float64(80), "hosts": []interface{}{"a"}},
+		"alt":  map[string]interface{}{"port": float64(8080), "hosts": []interface{}{}},
+	}}, out)
+
+	// At the top level, structs in interface values are also objects rather than blocks.
+	data, err = Marshal(&map[string]interface{}{"main": service{Port: 80}})
+	require.NoError(t, err)
+	require.Equal(t, `main = {
+  "port": 80,
+  "hosts": [],
+}
+`, string(data))
+}
+
+func TestMarshalASCIIOnly(t *testing.T) {
+	type block struct {
+		Name string `hcl:"name,label"`
+		Note string `hcl:"note,multiline"`
+	}
+	type config struct {
+		Greeting string            `hcl:"greeting"`
+		Names    map[string]string `hcl:"names"`
+		Blocks   []block           `hcl:"block,block"`
+	}
+	in := &config{
+		Greeting: "café 😀",
+		Names:    map[string]string{"josé": "é"},
+		Blocks:   []block{{Name: "naïve", Note: "über\nline"}},
+	}
+	data, err := Marshal(in, ASCIIOnly(true))
+	require.NoError(t, err)
+	require.Equal(t, `greeting = "caf\u00e9 \U0001f600"
+names = {
+  "jos\u00e9": "\u00e9",
+}
+
+block "na\u00efve" {
+  note = "\u00fcber\nline"
+}
+`, string(data))
+
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+type money float64
+
+func TestMarshalValueFormatter(t *testing.T) {
+	type item struct {
+		Name   string  `hcl:"name,label"`
+		Price  money   `hcl:"price"`
+		Prices []money `hcl:"prices"`
+	}
+	type config struct {
+		Items []item `hcl:"item,block"`
+	}
+	in := &config{Items: []item{{Name: "tea", Price: 3.5, Prices: []money{1, 2.25}}}}
+	formatMoney := WithValueFormatter(reflect.TypeOf(money(0)), func(v reflect.Value) (string, error) {
+		return fmt.Sprintf("%.2f", v.Float()), nil
+	})
+	data, err := Marshal(in, formatMoney)
+	require.NoError(t, err)
+	require.Equal(t, `item "tea" {
+  price = 3.50
+  prices = [1.00, 2.25]
+}
+`, string(data))
+
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	_, err = Marshal(in, WithValueFormatter(reflect.TypeOf(money(0)), func(v reflect.Value) (string, error) {
+		return "$" + fmt.Sprint(v.Float()), nil
+	}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `invalid formatted hcl.money value "$3.5"`)
+}
+
+func TestMarshalIndexComments(t *testing.T) {
+	type server struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type config struct {
+		Servers []server `hcl:"server,block" help:"A server."`
+	}
+	in := &config{Servers: []server{{Name: "a", Port: 1}, {Name: "b", Port: 2}}}
+	data, err := Marshal(in, IndexComments(true))
+	require.NoError(t, err)
+	require.Equal(t, `// A server.
+// [0]
+server "a" {
+  port = 1
+}
+
+// A server.
+// [1]
+server "b" {
+  port = 2
+}
+`, string(data))
+
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+}
+
+func TestCanMarshal(t *testing.T) {
+	type inner struct {
+		Name    string            `hcl:"name"`
+		Handler func()            `hcl:"handler"`
+		Ignored chan int          `hcl:"-"`
+		Counts  map[int]string    `hcl:"counts"`
+		Tags    map[string]string `hcl:"tags"`
+	}
+	type config struct {
+		Port    int                 `hcl:"port"`
+		Timeout time.Duration       `hcl:"timeout"`
+		Inner   *inner              `hcl:"inner,block"`
+		Events  []chan string       `hcl:"events"`
+		Any     interface{}         `hcl:"any"`
+		Nested  map[string][]*inner `hcl:"nested"`
+	}
+	err := CanMarshal(&config{})
+	require.EqualError(t, err, "can't marshal *hcl.config: "+
+		"*hcl.config.Inner.Handler: unsupported type func(); "+
+		"*hcl.config.Inner.Counts: map keys must be strings but are int; "+
+		"*hcl.config.Events: unsupported type chan string")
+
+	err = CanMarshal(&inner{}, SkipUnsupported(true))
+	require.EqualError(t, err, "can't marshal *hcl.inner: *hcl.inner.Counts: map keys must be strings but are int")
+
+	type valid struct {
+		Name  string   `hcl:"name"`
+		Hosts []string `hcl:"hosts"`
+	}
+	require.NoError(t, CanMarshal(&valid{}))
+}
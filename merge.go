@@ -0,0 +1,134 @@
+package hcl
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+// AppendBlocks specifies that when merging ASTs, blocks from later ASTs are appended
+// rather than merged into existing blocks with the same name and labels.
+func AppendBlocks(v bool) MarshalOption {
+	return func(options *marshalOptions) {
+		options.appendBlocks = v
+	}
+}
+
+// Merge the entries of other into the AST.
+//
+// Attributes in other override attributes with the same key. By default, blocks in other
+// with the same name and labels as an existing block are merged into it recursively, and
+// all other blocks are appended. If AppendBlocks(true) is passed, blocks are always appended.
+//
+// Only entries already in the AST are merged into, so repeated entries within other are kept.
+func (a *AST) Merge(other *AST, options ...MarshalOption) {
+	opt := newMarshalOptions(options...)
+	a.Entries = mergeEntries(a.Entries, other.Entries, opt)
+	a.TrailingComments = append(a.TrailingComments, cloneStrings(other.TrailingComments)...)
+	addParentRefs(nil, a)
+}
+
+// AddStruct marshals a Go struct and appends its entries to the AST.
+//
+// It is an error if an attribute or labelled block in v already exists in the AST, or if an
+// attribute and a block would have the same name. Unlabelled blocks may be repeated.
+func (a *AST) AddStruct(v interface{}, options ...MarshalOption) error {
+	other, err := MarshalToAST(v, options...)
+	if err != nil {
+		return err
+	}
+	for _, entry := range other.Entries {
+		for _, existing := range a.Entries {
+			if entry.Key() != existing.Key() {
+				continue
+			}
+			switch {
+			case entry.Attribute != nil && existing.Attribute != nil:
+				return fmt.Errorf("duplicate attribute %q", entry.Key())
+
+			case entry.Block == nil || existing.Block == nil:
+				return fmt.Errorf("%q cannot be both block and attribute", entry.Key())
+
+			case len(entry.Block.Labels) > 0 && equalStrings(entry.Block.Labels, existing.Block.Labels):
+				return fmt.Errorf("duplicate block %s %q", entry.Key(), entry.Block.Labels)
+			}
+		}
+	}
+	a.Entries = append(a.Entries, other.Entries...)
+	addParentRefs(nil, a)
+	return nil
+}
+
+func mergeEntries(dst, src []*Entry, opt *marshalOptions) []*Entry {
+	// Entries appended from src are not merge targets, so repeated entries in src are kept.
+	existing := len(dst)
+	for _, entry := range src {
+		entry = entry.Clone()
+		i := findMergeTarget(dst[:existing], entry, opt)
+		switch {
+		case i == -1:
+			dst = append(dst, entry)
+
+		case entry.Attribute != nil:
+			dst[i] = entry
+
+		default:
+			block := dst[i].Block
+			block.Body = mergeEntries(block.Body, entry.Block.Body, opt)
+			block.TrailingComments = append(block.TrailingComments, entry.Block.TrailingComments...)
+		}
+	}
+	return dst
+}
+
+func findMergeTarget(entries []*Entry, entry *Entry, opt *marshalOptions) int {
+	for i, candidate := range entries {
+		switch {
+		case entry.Attribute != nil && candidate.Attribute != nil:
+			if candidate.Attribute.Key == entry.Attribute.Key {
+				return i
+			}
+
+		case entry.Block != nil && candidate.Block != nil && !opt.appendBlocks:
+			if candidate.Block.Name == entry.Block.Name && equalStrings(candidate.Block.Labels, entry.Block.Labels) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// UnmarshalFiles parses and merges multiple HCL files, then unmarshals the result into a Go struct.
+//
+// Later files take precedence over earlier files, as described by AST.Merge().
+func UnmarshalFiles(paths []string, v interface{}, options ...MarshalOption) error {
+	opt := newMarshalOptions(options...)
+	merged := &AST{}
+	for _, path := range paths {
+		ast, err := parseFile(path, opt)
+		if err != nil {
+			return err
+		}
+		merged.Merge(ast, options...)
+	}
+	return UnmarshalAST(merged, v, options...)
+}
+
+func parseFile(path string, opt *marshalOptions) (*AST, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseWithOptions(data, opt)
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
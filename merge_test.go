@@ -0,0 +1,188 @@
+package hcl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmarshalFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hcl-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "base.hcl")
+	override := filepath.Join(dir, "override.hcl")
+	err = ioutil.WriteFile(base, []byte(`
+name = "base"
+port = 8080
+
+server "web" {
+  host = "localhost"
+}
+`), 0600)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(override, []byte(`
+port = 9090
+
+server "web" {
+  host = "example.com"
+}
+`), 0600)
+	require.NoError(t, err)
+
+	type server struct {
+		Name string `hcl:"name,label"`
+		Host string `hcl:"host"`
+	}
+	type config struct {
+		Name    string   `hcl:"name"`
+		Port    int      `hcl:"port"`
+		Servers []server `hcl:"server,block"`
+	}
+	actual := &config{}
+	err = UnmarshalFiles([]string{base, override}, actual)
+	require.NoError(t, err)
+	require.Equal(t, &config{
+		Name:    "base",
+		Port:    9090,
+		Servers: []server{{Name: "web", Host: "example.com"}},
+	}, actual)
+
+	actual = &config{}
+	err = UnmarshalFiles([]string{base, override}, actual, AppendBlocks(true))
+	require.NoError(t, err)
+	require.Equal(t, &config{
+		Name: "base",
+		Port: 9090,
+		Servers: []server{
+			{Name: "web", Host: "localhost"},
+			{Name: "web", Host: "example.com"},
+		},
+	}, actual)
+}
+
+func TestUnmarshalFilesRepeatedBlocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hcl-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "base.hcl")
+	override := filepath.Join(dir, "override.hcl")
+	err = ioutil.WriteFile(base, []byte(`
+service {
+  port = 1
+}
+
+service {
+  port = 2
+}
+`), 0600)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(override, []byte(`
+service {
+  port = 3
+}
+`), 0600)
+	require.NoError(t, err)
+
+	type service struct {
+		Port int `hcl:"port"`
+	}
+	type config struct {
+		Services []service `hcl:"service,block"`
+	}
+	data, err := ioutil.ReadFile(base)
+	require.NoError(t, err)
+	expected := &config{}
+	err = Unmarshal(data, expected)
+	require.NoError(t, err)
+	actual := &config{}
+	err = UnmarshalFiles([]string{base}, actual)
+	require.NoError(t, err)
+	require.Equal(t, &config{Services: []service{{Port: 1}, {Port: 2}}}, actual)
+	require.Equal(t, expected, actual)
+
+	// Blocks in later files merge into the first matching block of earlier files.
+	actual = &config{}
+	err = UnmarshalFiles([]string{base, override}, actual)
+	require.NoError(t, err)
+	require.Equal(t, &config{Services: []service{{Port: 3}, {Port: 2}}}, actual)
+}
+
+func TestMergeAST(t *testing.T) {
+	a := hcl(
+		attr("a", num(1)),
+		block("block", nil, attr("b", num(2)), attr("c", num(3))),
+	)
+	b := hcl(
+		attr("a", num(10)),
+		attr("m", hmap(hkv("k", str("v")))),
+		block("block", nil, attr("c", num(30))),
+		block("other", nil),
+	)
+	a.Merge(b)
+	data, err := MarshalAST(a)
+	require.NoError(t, err)
+	require.Equal(t, `a = 10
+
+block {
+  b = 2
+  c = 30
+}
+
+m = {
+  "k": "v",
+}
+
+other {
+}
+`, string(data))
+}
+
+func TestASTAddStruct(t *testing.T) {
+	type server struct {
+		Name string `hcl:"name,label"`
+		Port int    `hcl:"port"`
+	}
+	type app struct {
+		Version string   `hcl:"version"`
+		Servers []server `hcl:"server,block"`
+	}
+	type db struct {
+		Servers []server `hcl:"server,block"`
+		URL     string   `hcl:"url"`
+	}
+	ast := &AST{}
+	err := ast.AddStruct(&app{Version: "1", Servers: []server{{Name: "web", Port: 80}}})
+	require.NoError(t, err)
+	err = ast.AddStruct(&db{Servers: []server{{Name: "db", Port: 5432}}, URL: "postgres://"})
+	require.NoError(t, err)
+	data, err := MarshalAST(ast)
+	require.NoError(t, err)
+	require.Equal(t, `version = "1"
+
+server "web" {
+  port = 80
+}
+
+server "db" {
+  port = 5432
+}
+
+url = "postgres://"
+`, string(data))
+
+	err = ast.AddStruct(&db{Servers: []server{{Name: "web", Port: 8080}}, URL: "mysql://"})
+	require.EqualError(t, err, `duplicate block server ["web"]`)
+	err = ast.AddStruct(&struct {
+		URL string `hcl:"url"`
+	}{})
+	require.EqualError(t, err, `duplicate attribute "url"`)
+	err = ast.AddStruct(&struct {
+		Version struct{} `hcl:"version,block"`
+	}{})
+	require.EqualError(t, err, `"version" cannot be both block and attribute`)
+	require.Len(t, ast.Entries, 4)
+}
@@ -4,11 +4,15 @@
 package hcl
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/big"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/alecthomas/participle"
 	"github.com/alecthomas/participle/lexer"
@@ -23,6 +27,10 @@ type Node interface{ node() }
 type AST struct {
 	Pos lexer.Position `parser:"" json:"-"`
 
+	// Comments written above the first entry when marshalling, separated from it by a blank line.
+	//
+	// These are not populated by the parser, as comments preceding the first entry are attached to it.
+	LeadingComments  []string `parser:"" json:"leading_comments,omitempty"`
 	Entries          []*Entry `parser:"@@*" json:"entries,omitempty"`
 	TrailingComments []string `parser:"@Comment*" json:"trailing_comments,omitempty"`
 	Schema           bool     `parser:"" json:"schema,omitempty"`
@@ -35,6 +43,7 @@ func (a *AST) Clone() *AST {
 	}
 	out := &AST{
 		Pos:              a.Pos,
+		LeadingComments:  cloneStrings(a.LeadingComments),
 		TrailingComments: cloneStrings(a.TrailingComments),
 		Schema:           a.Schema,
 	}
@@ -92,11 +101,17 @@ type Attribute struct {
 
 	Comments []string `parser:"@Comment*" json:"comments,omitempty"`
 
-	Key   string `parser:"@Ident '='" json:"key"`
+	Key   string `parser:"@(Ident | String) '='" json:"key"`
 	Value *Value `parser:"@@" json:"value"`
 
 	// Set for schemas when the attribute is optional.
 	Optional bool `parser:"" json:"optional,omitempty"`
+
+	// Set for schemas when the attribute is written commented out, eg. "// port = 8080".
+	Commented bool `parser:"" json:"commented,omitempty"`
+
+	// Attributes in different groups are separated by a blank line when marshalling.
+	Group string `parser:"" json:"-"`
 }
 
 func (*Attribute) node() {}
@@ -111,11 +126,13 @@ func (a *Attribute) Clone() *Attribute {
 		return nil
 	}
 	return &Attribute{
-		Pos:      a.Pos,
-		Comments: cloneStrings(a.Comments),
-		Key:      a.Key,
-		Value:    a.Value.Clone(),
-		Optional: a.Optional,
+		Pos:       a.Pos,
+		Comments:  cloneStrings(a.Comments),
+		Key:       a.Key,
+		Value:     a.Value.Clone(),
+		Optional:  a.Optional,
+		Commented: a.Commented,
+		Group:     a.Group,
 	}
 }
 
@@ -165,7 +182,7 @@ type MapEntry struct {
 
 	Comments []string `parser:"@Comment*" json:"comments,omitempty"`
 
-	Key   *Value `parser:"@@ ':'" json:"key"`
+	Key   *Value `parser:"@@ ( ':' | '=' )" json:"key"`
 	Value *Value `parser:"@@" json:"value"`
 }
 
@@ -184,6 +201,11 @@ func (e *MapEntry) Clone() *MapEntry {
 	}
 }
 
+// Expression is an unevaluated HCL expression, such as a for expression, preserved verbatim.
+//
+// Expressions can only be unmarshalled into fields of this type.
+type Expression string
+
 // Bool represents a parsed boolean value.
 type Bool bool
 
@@ -195,15 +217,28 @@ type Value struct {
 	Parent Node           `parser:"" json:"-"`
 
 	Bool             *Bool       `parser:"(  @('true' | 'false')" json:"bool,omitempty"`
+	Null             bool        `parser:" | @'null'" json:"null,omitempty"`
 	Number           *big.Float  `parser:" | @Number" json:"number,omitempty"`
 	Type             *string     `parser:" | @('number':Ident | 'string':Ident | 'boolean':Ident)" json:"type,omitempty"`
 	Str              *string     `parser:" | @(String | Ident)" json:"str,omitempty"`
 	HeredocDelimiter string      `parser:" | (@Heredoc" json:"heredoc_delimiter,omitempty"`
 	Heredoc          *string     `parser:"     @(Body | EOL)* End)" json:"heredoc,omitempty"`
+	Expr             *string     `parser:" | @(ForStart (ForNested | ForEnd | ForString | ForBody)*)" json:"expr,omitempty"`
 	HaveList         bool        `parser:" | ( @'['" json:"have_list,omitempty"` // Need this to detect empty lists.
 	List             []*Value    `parser:"     ( @@ ( ',' @@ )* )? ','? ']' )" json:"list,omitempty"`
 	HaveMap          bool        `parser:" | ( @'{'" json:"have_map,omitempty"` // Need this to detect empty maps.
 	Map              []*MapEntry `parser:"     ( @@ ( ',' @@ )* ','? )? '}' ) )" json:"map,omitempty"`
+
+	// Radix to format an integer Number in when marshalling: 2, 8, 16, or 0 for decimal.
+	Radix int `parser:"" json:"-"`
+	// Marshal Str as a bare identifier rather than a quoted string.
+	Ident bool `parser:"" json:"-"`
+	// Number was parsed from a floating point literal, eg. 1.0 or 1e3, so is always marshalled as one.
+	Float bool `parser:"" json:"-"`
+	// Literal text written in place of the value when marshalling, see WithValueFormatter.
+	Raw string `parser:"" json:"-"`
+	// Source text of a parsed Number, eg. "1.10", used to decode json.Number exactly.
+	Literal string `parser:"" json:"-"`
 }
 
 // Clone the AST.
@@ -226,7 +261,7 @@ func (v *Value) Clone() *Value {
 
 	case v.HaveMap:
 		out.Map = make([]*MapEntry, len(v.Map))
-		for i, entry := range out.Map {
+		for i, entry := range v.Map {
 			out.Map[i] = entry.Clone()
 		}
 	}
@@ -237,21 +272,42 @@ func (*Value) node() {}
 
 func (v *Value) String() string {
 	switch {
+	case v.Raw != "":
+		return v.Raw
+
 	case v.Bool != nil:
 		return fmt.Sprintf("%v", *v.Bool)
 
+	case v.Null:
+		return "null"
+
 	case v.Number != nil:
-		return v.Number.String()
+		if v.Radix != 0 {
+			return formatRadix(v.Number, v.Radix)
+		}
+		s := formatNumber(v.Number)
+		if v.Float && !strings.ContainsAny(s, ".eI") {
+			s += ".0"
+		}
+		return s
+
+	case v.Str != nil && v.Ident:
+		return *v.Str
 
 	case v.Str != nil:
+		// %q escapes invalid UTF-8 as \x sequences, so the output is always valid UTF-8.
 		return fmt.Sprintf("%q", *v.Str)
 
+	case v.Expr != nil:
+		return *v.Expr
+
 	case v.HeredocDelimiter != "":
 		heredoc := ""
 		if v.Heredoc != nil {
 			heredoc = *v.Heredoc
 		}
-		return fmt.Sprintf("<<%s%s\n%s", v.HeredocDelimiter, heredoc, v.HeredocDelimiter)
+		// The "-" of an indented heredoc is only part of the opening delimiter.
+		return fmt.Sprintf("<<%s%s\n%s", v.HeredocDelimiter, heredoc, strings.TrimPrefix(v.HeredocDelimiter, "-"))
 
 	case v.HaveList:
 		entries := []string{}
@@ -275,6 +331,41 @@ func (v *Value) String() string {
 	}
 }
 
+// formatNumber formats integers exactly, avoiding big.Float's default formatting where possible.
+func formatNumber(n *big.Float) string {
+	if n.IsInt() {
+		if i, acc := n.Int64(); acc == big.Exact {
+			return strconv.FormatInt(i, 10)
+		}
+		if u, acc := n.Uint64(); acc == big.Exact {
+			return strconv.FormatUint(u, 10)
+		}
+		// Integers with all of their digits significant, rather than eg. 1e300.
+		if n.MantExp(nil) <= int(n.Prec()) {
+			return n.Text('f', 0)
+		}
+	}
+	// The shortest representation that uniquely identifies the value at its precision.
+	return n.Text('g', -1)
+}
+
+// formatRadix formats integers in base 2, 8 or 16 with a 0b, 0o or 0x prefix respectively.
+//
+// Non-integers are formatted as decimal.
+func formatRadix(n *big.Float, radix int) string {
+	prefix := map[int]string{2: "0b", 8: "0o", 16: "0x"}[radix]
+	if !n.IsInt() || prefix == "" {
+		return formatNumber(n)
+	}
+	i, _ := n.Int(nil)
+	sign := ""
+	if i.Sign() < 0 {
+		sign = "-"
+		i.Neg(i)
+	}
+	return sign + prefix + i.Text(radix)
+}
+
 // GetHeredoc gets the heredoc as a string.
 //
 // This will correctly format indented heredocs.
@@ -297,28 +388,67 @@ var (
 	lex = lexer.Must(stateful.New(stateful.Rules{
 		"Root": {
 			{"Ident", `\b[[:alpha:]]\w*(-\w+)*\b`, nil},
-			{"Number", `\b^[-+]?[0-9]*\.?[0-9]+([eE][-+]?[0-9]+)?\b`, nil},
+			{"Number", `[-+]?(?:0[xX][0-9a-fA-F](?:_?[0-9a-fA-F])*|0[oO][0-7](?:_?[0-7])*|0[bB][01](?:_?[01])*|(?:[0-9](?:_?[0-9])*)?\.?[0-9](?:_?[0-9])*(?:[eE][-+]?[0-9]+)?)\b`, nil},
 			{"Heredoc", `<<[-]?(\w+\b)`, stateful.Push("Heredoc")},
 			{"String", `"(\\\d\d\d|\\.|[^"])*"`, nil},
+			{"ForStart", `[[{]\s*for\s+\w+(?:\s*,\s*\w+)?\s+in\s`, stateful.Push("ForExpr")},
 			{"Punct", `[][{}=:,]`, nil},
 			{"Comment", `(?:(?://|#)[^\n]*)|/\*.*?\*/`, nil},
 			{"whitespace", `\s+`, nil},
 		},
+		// For expressions are preserved verbatim, so we just track nesting.
+		"ForExpr": {
+			{"ForNested", `[[{]`, stateful.Push("ForExpr")},
+			{"ForEnd", `[]}]`, stateful.Pop()},
+			{"ForString", `"(?:\\.|[^"])*"`, nil},
+			{"ForBody", `[^][{}"]+`, nil},
+		},
 		"Heredoc": {
-			{"End", `\n\b\1\b`, stateful.Pop()},
+			{"End", `\n[ \t]*\b\1\b`, stateful.Pop()},
 			{"EOL", `\n`, nil},
 			{"Body", `[^\n]+`, nil},
 		},
 	}))
 	parser = participle.MustBuild(&AST{},
 		participle.Lexer(lex),
-		participle.Unquote("String"),
+		participle.Map(unquoteString, "String"),
 		participle.Map(cleanHeredocStart, "Heredoc"),
 		participle.Map(stripComment, "Comment"),
 		// We need lookahead to ensure prefixed comments are associated with the right nodes.
 		participle.UseLookahead(50))
+	// colonParser additionally accepts "key: value" attributes, for AllowColonAssignment().
+	colonParser = participle.MustBuild(&AST{},
+		participle.Lexer(lex),
+		participle.Map(unquoteString, "String"),
+		participle.Map(cleanHeredocStart, "Heredoc"),
+		participle.Map(stripComment, "Comment"),
+		participle.Map(colonToEquals, "Punct"),
+		participle.UseLookahead(50))
 )
 
+// unquoteString is like participle.Unquote except that byte escapes such as \xff
+// decode to raw bytes, so that strings containing invalid UTF-8 round-trip.
+func unquoteString(token lexer.Token) (lexer.Token, error) {
+	s := token.Value[1 : len(token.Value)-1]
+	out := make([]byte, 0, len(s))
+	var buf [utf8.UTFMax]byte
+	for s != "" {
+		value, multibyte, tail, err := strconv.UnquoteChar(s, '"')
+		if err != nil {
+			return token, lexer.ErrorWithTokenf(token, "invalid quoted string %q: %s", token.Value, err.Error())
+		}
+		s = tail
+		if multibyte {
+			n := utf8.EncodeRune(buf[:], value)
+			out = append(out, buf[:n]...)
+		} else {
+			out = append(out, byte(value))
+		}
+	}
+	token.Value = string(out)
+	return token, nil
+}
+
 var stripCommentRe = regexp.MustCompile(`^//\s*|^/\*|\*/$`)
 
 func stripComment(token lexer.Token) (lexer.Token, error) {
@@ -326,6 +456,16 @@ func stripComment(token lexer.Token) (lexer.Token, error) {
 	return token, nil
 }
 
+// : -> =
+//
+// Map entries accept either separator, so this only changes how attributes are parsed.
+func colonToEquals(token lexer.Token) (lexer.Token, error) {
+	if token.Value == ":" {
+		token.Value = "="
+	}
+	return token, nil
+}
+
 // <<EOF -> EOF
 func cleanHeredocStart(token lexer.Token) (lexer.Token, error) {
 	token.Value = token.Value[2:]
@@ -334,34 +474,160 @@ func cleanHeredocStart(token lexer.Token) (lexer.Token, error) {
 
 // Parse HCL from an io.Reader.
 func Parse(r io.Reader) (*AST, error) {
-	hcl := &AST{}
-	err := parser.Parse(r, hcl)
+	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return hcl, AddParentRefs(hcl)
+	return ParseBytes(data)
 }
 
-// ParseString parses HCL from a string.
-func ParseString(str string) (*AST, error) {
-	hcl := &AST{}
-	err := parser.ParseString(str, hcl)
+// ParseAll parses multiple HCL documents from an io.Reader, separated by lines containing only "---".
+//
+// As "---" is not valid HCL, the separator cannot otherwise occur outside heredocs, however a heredoc
+// line containing only "---" is also treated as a separator. Each document is parsed independently,
+// and positions are relative to the start of the input. Empty documents, eg. before a leading
+// separator, result in empty ASTs.
+func ParseAll(r io.Reader) ([]*AST, error) {
+	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return hcl, AddParentRefs(hcl)
+	out := []*AST{}
+	parse := func(start, end, line int) error {
+		// Documents start at the beginning of a line, so only lines and offsets need adjusting.
+		base := lexer.Position{Offset: start, Line: line - 1}
+		ast, err := ParseBytes(data[start:end])
+		if perr, ok := err.(participle.Error); ok {
+			return participle.Errorf(addPosition(perr.Token().Pos, base), "%s", perr.Message())
+		} else if err != nil {
+			return err
+		}
+		out = append(out, ast)
+		return Visit(ast, func(node Node, next func() error) error {
+			switch node := node.(type) {
+			case *AST:
+				node.Pos = addPosition(node.Pos, base)
+			case *Entry:
+				node.Pos = addPosition(node.Pos, base)
+			case *Attribute:
+				node.Pos = addPosition(node.Pos, base)
+			case *Block:
+				node.Pos = addPosition(node.Pos, base)
+			case *MapEntry:
+				node.Pos = addPosition(node.Pos, base)
+			case *Value:
+				node.Pos = addPosition(node.Pos, base)
+			}
+			return next()
+		})
+	}
+	start, startLine := 0, 1
+	for offset, line := 0, 1; offset < len(data); line++ {
+		end := bytes.IndexByte(data[offset:], '\n') + offset + 1
+		if end == offset {
+			end = len(data)
+		}
+		if string(bytes.TrimRight(data[offset:end], " \t\r\n")) == "---" {
+			if err := parse(start, offset, startLine); err != nil {
+				return nil, err
+			}
+			start, startLine = end, line+1
+		}
+		offset = end
+	}
+	if err := parse(start, len(data), startLine); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// addPosition returns pos offset by the lines and bytes of base.
+func addPosition(pos, base lexer.Position) lexer.Position {
+	pos.Offset += base.Offset
+	pos.Line += base.Line
+	return pos
+}
+
+// parseValue parses a single HCL value, such as "1.5" or "[\"a\"]".
+func parseValue(s string) (*Value, error) {
+	ast, err := ParseString("value = " + s)
+	if err != nil {
+		return nil, err
+	}
+	if len(ast.Entries) != 1 || ast.Entries[0].Attribute == nil {
+		return nil, fmt.Errorf("expected a single value but got %q", s)
+	}
+	value := ast.Entries[0].Attribute.Value
+	value.Parent = nil
+	return value, nil
+}
+
+// ParseString parses HCL from a string.
+func ParseString(str string) (*AST, error) {
+	return ParseBytes([]byte(str))
 }
 
 // ParseBytes parses HCL from bytes.
 func ParseBytes(data []byte) (*AST, error) {
+	return parseBytes(parser, data)
+}
+
+func parseBytes(p *participle.Parser, data []byte) (*AST, error) {
 	hcl := &AST{}
-	err := parser.ParseBytes(data, hcl)
+	err := p.ParseBytes(data, hcl)
 	if err != nil {
 		return nil, err
 	}
+	if err := reparseNumbers(hcl, data); err != nil {
+		return nil, err
+	}
 	return hcl, AddParentRefs(hcl)
 }
 
+var numberRe = regexp.MustCompile(`^[-+]?(?:0[xX][0-9a-fA-F_]+|0[oO][0-7_]+|0[bB][01_]+|[0-9_]*\.?[0-9_]+(?:[eE][-+]?[0-9]+)?)`)
+
+// reparseNumbers re-parses number literals from the source with enough precision to represent
+// them exactly, as the parser only provides big.Float's default 64 bits of precision.
+func reparseNumbers(ast *AST, source []byte) error {
+	return Visit(ast, func(node Node, next func() error) error {
+		value, ok := node.(*Value)
+		if !ok || value.Number == nil || value.Pos.Offset >= len(source) {
+			return next()
+		}
+		text := numberRe.Find(source[value.Pos.Offset:])
+		if text == nil {
+			return next()
+		}
+		n, _, err := big.ParseFloat(string(text), 0, numberPrecision(len(text)), big.ToNearestEven)
+		if err != nil {
+			return participle.Wrapf(value.Pos, err, "invalid number")
+		}
+		value.Number = n
+		value.Float = isFloatLiteral(string(text))
+		value.Literal = string(text)
+		return next()
+	})
+}
+
+// isFloatLiteral returns true if a number literal is written in floating point form, eg. 1.0 or 1e3.
+func isFloatLiteral(text string) bool {
+	text = strings.ToLower(strings.TrimLeft(text, "+-"))
+	if strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0o") || strings.HasPrefix(text, "0b") {
+		return false
+	}
+	return strings.ContainsAny(text, ".e")
+}
+
+// numberPrecision returns the number of bits of precision needed to represent a literal of the
+// given length exactly, or at least as precisely as big.Float's default.
+func numberPrecision(length int) uint {
+	// log2(10) < 4 bits per decimal digit.
+	if prec := uint(length) * 4; prec > 64 {
+		return prec
+	}
+	return 64
+}
+
 func cloneStrings(strings []string) []string {
 	if strings == nil {
 		return nil
@@ -3,6 +3,7 @@ package hcl
 import (
 	"fmt"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/alecthomas/participle/lexer"
@@ -24,6 +25,21 @@ func TestGetHeredoc(t *testing.T) {
 	require.Equal(t, "  hello\n  world", value.GetHeredoc())
 }
 
+func TestHeredocStringRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		"a = <<EOF\n  hello\n  world\nEOF\n",
+		"a = <<-EOF\n    hello\n  world\n  EOF\n",
+	} {
+		ast, err := ParseString(src)
+		require.NoError(t, err)
+		value := ast.Entries[0].Attribute.Value
+		reparsed, err := parseValue(value.String())
+		require.NoError(t, err, value.String())
+		require.Equal(t, value.HeredocDelimiter, reparsed.HeredocDelimiter)
+		require.Equal(t, value.GetHeredoc(), reparsed.GetHeredoc())
+	}
+}
+
 func TestClone(t *testing.T) {
 	ast, err := ParseString(complexHCLExample)
 	require.NoError(t, err)
@@ -197,6 +213,7 @@ func normaliseEntries(entries []*Entry) {
 func normaliseValue(val *Value) {
 	val.Pos = lexer.Position{}
 	val.Parent = nil
+	val.Literal = ""
 	for _, entry := range val.Map {
 		entry.Pos = lexer.Position{}
 		entry.Parent = nil
@@ -250,5 +267,98 @@ func str(s string) *Value {
 func num(n float64) *Value {
 	s := fmt.Sprintf("%g", n)
 	b, _, _ := big.ParseFloat(s, 10, 64, 0)
-	return &Value{Number: b}
+	return &Value{Number: b, Float: strings.ContainsAny(s, ".e")}
+}
+
+func TestParseForExpression(t *testing.T) {
+	src := `tags = { for k, v in var.map : k => upper(v) if v != "}" }
+names = [for s in var.list : "${s}-x"]
+
+block {
+  for = 1
+}
+`
+	ast, err := ParseString(src)
+	require.NoError(t, err)
+	require.Equal(t, `{ for k, v in var.map : k => upper(v) if v != "}" }`, *ast.Entries[0].Attribute.Value.Expr)
+	data, err := MarshalAST(ast)
+	require.NoError(t, err)
+	require.Equal(t, src, string(data))
+
+	type conf struct {
+		Tags  Expression `hcl:"tags"`
+		Names []string   `hcl:"names"`
+		Block struct {
+			For int `hcl:"for"`
+		} `hcl:"block,block"`
+	}
+	err = UnmarshalAST(ast, &conf{})
+	require.EqualError(t, err, `2:9: attribute "names": expressions can only be unmarshalled into hcl.Expression, not []string`)
+}
+
+func TestParseNumberKindRoundTrip(t *testing.T) {
+	src := `int = 1
+float = 1.0
+exponent = 1e3
+negative = -2.0
+fraction = 2.5
+hex = 0x1f
+list = [1, 1.0, 3]
+`
+	ast, err := ParseString(src)
+	require.NoError(t, err)
+	require.False(t, ast.Entries[0].Attribute.Value.Float)
+	require.True(t, ast.Entries[1].Attribute.Value.Float)
+	data, err := MarshalAST(ast)
+	require.NoError(t, err)
+	require.Equal(t, `int = 1
+float = 1.0
+exponent = 1000.0
+negative = -2.0
+fraction = 2.5
+hex = 31
+list = [1, 1.0, 3]
+`, string(data))
+}
+
+func TestParseAll(t *testing.T) {
+	asts, err := ParseAll(strings.NewReader(`
+name = "first"
+---
+name = "second"
+server {
+  port = 80
+}
+`))
+	require.NoError(t, err)
+	require.Len(t, asts, 2)
+	data, err := MarshalAST(asts[0])
+	require.NoError(t, err)
+	require.Equal(t, "name = \"first\"\n", string(data))
+	data, err = MarshalAST(asts[1])
+	require.NoError(t, err)
+	require.Equal(t, "name = \"second\"\n\nserver {\n  port = 80\n}\n", string(data))
+	require.Equal(t, 4, asts[1].Entries[0].Pos.Line)
+	port := asts[1].Entries[1].Block.Body[0].Attribute.Value
+	require.Equal(t, lexer.Position{Offset: 54, Line: 6, Column: 10}, port.Pos)
+	require.Equal(t, "80", port.Literal)
+
+	type config struct {
+		Name string `hcl:"name"`
+	}
+	out := &config{}
+	err = UnmarshalAST(asts[0], out)
+	require.NoError(t, err)
+	require.Equal(t, "first", out.Name)
+
+	asts, err = ParseAll(strings.NewReader("---\na = 1\n---\n"))
+	require.NoError(t, err)
+	require.Len(t, asts, 3)
+	require.Empty(t, asts[0].Entries)
+	require.Len(t, asts[1].Entries, 1)
+	require.Empty(t, asts[2].Entries)
+
+	_, err = ParseAll(strings.NewReader("a = 1\n---\nb = \n"))
+	require.Error(t, err)
+	require.True(t, strings.HasPrefix(err.Error(), `4:1: unexpected token "<EOF>"`), err.Error())
 }
@@ -0,0 +1,72 @@
+package hcl
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+)
+
+// MarshalFunc converts a Go value into a HCL Value.
+type MarshalFunc func(v reflect.Value) (*Value, error)
+
+// UnmarshalFunc decodes a HCL Value into the Go value "dest".
+type UnmarshalFunc func(v *Value, dest reflect.Value) error
+
+type registeredType struct {
+	marshal   MarshalFunc
+	unmarshal UnmarshalFunc
+}
+
+var typeRegistry = map[reflect.Type]registeredType{}
+
+var converterRegistry = map[string]UnmarshalFunc{}
+
+var regexpType = reflect.TypeOf(regexp.Regexp{})
+
+func init() {
+	// regexp.Regexp implements neither encoding.TextMarshaler nor encoding.TextUnmarshaler, so is
+	// marshalled as its pattern, and compiled when unmarshalling.
+	RegisterType(regexpType,
+		func(v reflect.Value) (*Value, error) {
+			if !v.CanAddr() {
+				copied := reflect.New(regexpType)
+				copied.Elem().Set(v)
+				v = copied.Elem()
+			}
+			s := v.Addr().Interface().(*regexp.Regexp).String()
+			return &Value{Str: &s}, nil
+		},
+		func(v *Value, dest reflect.Value) error {
+			if v.Str == nil {
+				return fmt.Errorf("expected a regular expression string but got %s", v)
+			}
+			re, err := regexp.Compile(*v.Str)
+			if err != nil {
+				return err
+			}
+			dest.Set(reflect.ValueOf(re).Elem())
+			return nil
+		})
+}
+
+// RegisterType registers functions used to marshal and unmarshal values of type "t".
+//
+// Registered types take precedence over all built-in handling. Either function may be nil, in
+// which case the default behaviour is used for that direction.
+//
+// The registry is global and not safe for concurrent use. RegisterType must only be called during
+// program initialisation, eg. from an init() function, before any marshalling or unmarshalling occurs.
+func RegisterType(t reflect.Type, marshal MarshalFunc, unmarshal UnmarshalFunc) {
+	typeRegistry[t] = registeredType{marshal: marshal, unmarshal: unmarshal}
+}
+
+// RegisterConverter registers a named function used to unmarshal fields tagged with "convert=<name>".
+//
+// eg. `hcl:"size,convert=bytes"` decodes "size" with the converter registered as "bytes". The
+// converter receives the non-null attribute value and the destination, with pointers already
+// allocated. Converters only apply when unmarshalling; such fields are marshalled as usual.
+//
+// As with RegisterType, the registry is global and must only be modified during program initialisation.
+func RegisterConverter(name string, unmarshal UnmarshalFunc) {
+	converterRegistry[name] = unmarshal
+}
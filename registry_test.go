@@ -0,0 +1,134 @@
+package hcl
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type registeredPoint struct{ X, Y int }
+
+func init() {
+	RegisterConverter("bytes", func(v *Value, dest reflect.Value) error {
+		if v.Str == nil {
+			return fmt.Errorf("expected a size string but got %s", v)
+		}
+		units := map[string]int64{"B": 1, "KB": 1 << 10, "MB": 1 << 20}
+		for _, suffix := range []string{"KB", "MB", "B"} {
+			if strings.HasSuffix(*v.Str, suffix) {
+				n, err := strconv.ParseInt(strings.TrimSuffix(*v.Str, suffix), 10, 64)
+				if err != nil {
+					return err
+				}
+				dest.SetInt(n * units[suffix])
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown size unit in %q", *v.Str)
+	})
+	RegisterType(reflect.TypeOf(registeredPoint{}),
+		func(v reflect.Value) (*Value, error) {
+			p := v.Interface().(registeredPoint)
+			s := fmt.Sprintf("%d,%d", p.X, p.Y)
+			return &Value{Str: &s}, nil
+		},
+		func(v *Value, dest reflect.Value) error {
+			if v.Str == nil {
+				return fmt.Errorf("expected a string but got %s", v)
+			}
+			p := registeredPoint{}
+			_, err := fmt.Sscanf(*v.Str, "%d,%d", &p.X, &p.Y)
+			if err != nil {
+				return err
+			}
+			dest.Set(reflect.ValueOf(p))
+			return nil
+		})
+}
+
+func TestRegisterType(t *testing.T) {
+	type conf struct {
+		Point  registeredPoint   `hcl:"point"`
+		Points []registeredPoint `hcl:"points"`
+	}
+	src := &conf{
+		Point:  registeredPoint{1, 2},
+		Points: []registeredPoint{{3, 4}},
+	}
+	data, err := Marshal(src)
+	require.NoError(t, err)
+	require.Equal(t, "point = \"1,2\"\npoints = [\"3,4\"]\n", string(data))
+
+	actual := &conf{}
+	err = Unmarshal(data, actual)
+	require.NoError(t, err)
+	require.Equal(t, src, actual)
+
+	err = Unmarshal([]byte(`
+point = 1
+points = []
+`), actual)
+	require.EqualError(t, err, "2:9: attribute \"point\": invalid value: expected a string but got 1")
+}
+
+func TestRegisterConverter(t *testing.T) {
+	type conf struct {
+		Size  int64 `hcl:"size,convert=bytes"`
+		Limit *int  `hcl:"limit,convert=bytes,optional"`
+	}
+	actual := &conf{}
+	err := Unmarshal([]byte(`
+size = "1KB"
+limit = "2MB"
+`), actual)
+	require.NoError(t, err)
+	limit := 2 << 20
+	require.Equal(t, &conf{Size: 1024, Limit: &limit}, actual)
+
+	err = Unmarshal([]byte(`size = "lots"`), actual)
+	require.EqualError(t, err, `1:8: attribute "size": invalid value: unknown size unit in "lots"`)
+
+	type unknown struct {
+		Size int `hcl:"size,convert=missing"`
+	}
+	err = Unmarshal([]byte(`size = 1`), &unknown{})
+	require.EqualError(t, err, `unknown converter "missing" on github.com/alecthomas/hcl.unknown.Size`)
+}
+
+func TestRegexp(t *testing.T) {
+	type conf struct {
+		Match   *regexp.Regexp   `hcl:"match"`
+		Exclude []*regexp.Regexp `hcl:"exclude,optional"`
+	}
+	src := &conf{
+		Match:   regexp.MustCompile(`^(\w+)\.example\.com$`),
+		Exclude: []*regexp.Regexp{regexp.MustCompile(`^test-`)},
+	}
+	data, err := Marshal(src)
+	require.NoError(t, err)
+	require.Equal(t, `match = "^(\\w+)\\.example\\.com$"
+exclude = ["^test-"]
+`, string(data))
+
+	actual := &conf{}
+	err = Unmarshal(data, actual)
+	require.NoError(t, err)
+	require.Equal(t, src.Match.String(), actual.Match.String())
+	require.True(t, actual.Match.MatchString("www.example.com"))
+	require.Len(t, actual.Exclude, 1)
+	require.Equal(t, "^test-", actual.Exclude[0].String())
+
+	err = Unmarshal([]byte(`match = "("`), actual)
+	require.EqualError(t, err, "1:9: attribute \"match\": invalid value: error parsing regexp: missing closing ): `(`")
+
+	schema, err := Schema(&conf{})
+	require.NoError(t, err)
+	data, err = MarshalAST(schema)
+	require.NoError(t, err)
+	require.Equal(t, "match = string\nexclude = [string] // (optional)\n", string(data))
+}
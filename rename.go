@@ -0,0 +1,58 @@
+package hcl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Rename the attribute or block at the dotted path "oldPath", eg. "server.timeout", to "newPath".
+//
+// Path elements are attribute keys and block names, as with MarshalFields. Repeated blocks matching
+// a path element are all descended into or renamed. Comments and values are preserved. Only the
+// final element may differ, ie. entries can be renamed but not moved between blocks. It is an error
+// if no entry matches "oldPath" or an entry already exists at "newPath".
+func (a *AST) Rename(oldPath, newPath string) error {
+	oldKeys := strings.Split(oldPath, ".")
+	newKeys := strings.Split(newPath, ".")
+	if len(oldKeys) != len(newKeys) || !equalStrings(oldKeys[:len(oldKeys)-1], newKeys[:len(newKeys)-1]) {
+		return fmt.Errorf("can't rename %q to %q as they are in different blocks", oldPath, newPath)
+	}
+	oldKey, newKey := oldKeys[len(oldKeys)-1], newKeys[len(newKeys)-1]
+	if newKey == "" {
+		return fmt.Errorf("invalid path %q", newPath)
+	}
+	bodies := [][]*Entry{a.Entries}
+	for _, key := range oldKeys[:len(oldKeys)-1] {
+		next := [][]*Entry{}
+		for _, body := range bodies {
+			for _, entry := range body {
+				if entry.Block != nil && entry.Block.Name == key {
+					next = append(next, entry.Block.Body)
+				}
+			}
+		}
+		bodies = next
+	}
+	matches := []*Entry{}
+	for _, body := range bodies {
+		for _, entry := range body {
+			if entry.Key() == newKey && newKey != oldKey {
+				return fmt.Errorf("%s: can't rename %q to %q as it already exists", entry.Pos, oldPath, newPath)
+			}
+			if entry.Key() == oldKey {
+				matches = append(matches, entry)
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("no attribute or block matches %q", oldPath)
+	}
+	for _, entry := range matches {
+		if entry.Attribute != nil {
+			entry.Attribute.Key = newKey
+		} else {
+			entry.Block.Name = newKey
+		}
+	}
+	return nil
+}
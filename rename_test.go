@@ -0,0 +1,117 @@
+package hcl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestASTRename(t *testing.T) {
+	src := `
+// The name.
+name = "web"
+
+server {
+  // Seconds.
+  timeout = 30
+}
+
+listener "http" {
+  port = 80
+}
+
+listener "https" {
+  port = 443
+}
+`
+	tests := []struct {
+		name     string
+		old      string
+		new      string
+		expected string
+		fail     string
+	}{
+		{name: "Attribute", old: "name", new: "title", expected: `// The name.
+title = "web"
+
+server {
+  // Seconds.
+  timeout = 30
+}
+
+listener "http" {
+  port = 80
+}
+
+listener "https" {
+  port = 443
+}
+`},
+		{name: "NestedAttribute", old: "server.timeout", new: "server.timeout_seconds", expected: `// The name.
+name = "web"
+
+server {
+  // Seconds.
+  timeout_seconds = 30
+}
+
+listener "http" {
+  port = 80
+}
+
+listener "https" {
+  port = 443
+}
+`},
+		{name: "Block", old: "server", new: "http_server", expected: `// The name.
+name = "web"
+
+http_server {
+  // Seconds.
+  timeout = 30
+}
+
+listener "http" {
+  port = 80
+}
+
+listener "https" {
+  port = 443
+}
+`},
+		{name: "RepeatedBlocks", old: "listener.port", new: "listener.bind_port", expected: `// The name.
+name = "web"
+
+server {
+  // Seconds.
+  timeout = 30
+}
+
+listener "http" {
+  bind_port = 80
+}
+
+listener "https" {
+  bind_port = 443
+}
+`},
+		{name: "NotFound", old: "server.port", new: "server.bind_port", fail: `no attribute or block matches "server.port"`},
+		{name: "Collision", old: "name", new: "server", fail: `5:1: can't rename "name" to "server" as it already exists`},
+		{name: "Move", old: "server.timeout", new: "timeout", fail: `can't rename "server.timeout" to "timeout" as they are in different blocks`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ast, err := ParseString(src)
+			require.NoError(t, err)
+			err = ast.Rename(test.old, test.new)
+			if test.fail != "" {
+				require.EqualError(t, err, test.fail)
+				return
+			}
+			require.NoError(t, err)
+			data, err := MarshalAST(ast)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, string(data))
+		})
+	}
+}
@@ -2,7 +2,9 @@ package hcl
 
 import (
 	"fmt"
+	"math/big"
 	"reflect"
+	"strings"
 )
 
 // Schema reflects a schema from a Go value.
@@ -57,7 +59,10 @@ var (
 )
 
 func attrSchema(t reflect.Type) (*Value, error) {
-	if t == durationType || t == timeType || typeImplements(t, textMarshalerInterface) || typeImplements(t, jsonMarshalerInterface) {
+	if t == bigFloatType {
+		return &Value{Type: &numType}, nil
+	}
+	if t == durationType || t == timeType || t == regexpType || typeImplements(t, textMarshalerInterface) || typeImplements(t, jsonMarshalerInterface) {
 		return &Value{Type: &strType}, nil
 	}
 	switch t.Kind() {
@@ -71,6 +76,19 @@ func attrSchema(t reflect.Type) (*Value, error) {
 		}
 		return &Value{List: []*Value{el}, HaveList: true}, nil
 
+	case reflect.Array:
+		// Fixed-size arrays are tuples, with one element type per position. There is no AST node for
+		// a type constraint call, so it is written as is.
+		el, err := attrSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		elems := make([]string, t.Len())
+		for i := range elems {
+			elems[i] = inlineString(el, &marshalOptions{})
+		}
+		return &Value{Raw: "tuple([" + strings.Join(elems, ", ") + "])"}, nil
+
 	case reflect.Map:
 		el, err := attrSchema(t.Elem())
 		if err != nil {
@@ -93,7 +111,7 @@ func attrSchema(t reflect.Type) (*Value, error) {
 		return attrSchema(t.Elem())
 
 	default:
-		panic(fmt.Sprintf("unsupported attribute type %s during schema reflection", t))
+		return nil, unsupportedTypeError{t}
 	}
 }
 
@@ -107,3 +125,48 @@ func sliceToBlockSchema(t reflect.Type, tag tag, opt *marshalOptions) (*Block, e
 	block.Body, block.Labels, err = structToEntries(reflect.New(t.Elem()).Elem(), true, opt)
 	return block, err
 }
+
+// sampleValue replaces the type keywords in a schema value with sample values.
+func sampleValue(v *Value) *Value {
+	switch {
+	case v.Type != nil && *v.Type == strType:
+		s := "..."
+		return &Value{Str: &s}
+
+	case v.Type != nil && *v.Type == numType:
+		return &Value{Number: big.NewFloat(0)}
+
+	case v.Type != nil && *v.Type == boolType:
+		b := Bool(false)
+		return &Value{Bool: &b}
+
+	case v.HaveList:
+		out := &Value{HaveList: true, List: []*Value{}}
+		for _, el := range v.List {
+			out.List = append(out.List, sampleValue(el))
+		}
+		return out
+
+	case v.HaveMap:
+		out := &Value{HaveMap: true, Map: []*MapEntry{}}
+		for _, entry := range v.Map {
+			key := "key"
+			out.Map = append(out.Map, &MapEntry{Key: &Value{Str: &key}, Value: sampleValue(entry.Value)})
+		}
+		return out
+
+	default:
+		return v
+	}
+}
+
+// parseDefault parses the value of a `default:""` tag for a field of type t.
+func parseDefault(t reflect.Type, def string) (*Value, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String {
+		return &Value{Str: &def}, nil
+	}
+	return parseValue(def)
+}
@@ -226,3 +226,145 @@ options {
     `
 	require.Equal(t, strings.TrimSpace(expectedSchema), strings.TrimSpace(string(data)))
 }
+
+func TestTupleSchema(t *testing.T) {
+	type config struct {
+		Pair   [2]string  `hcl:"pair"`
+		Point  [3]float64 `hcl:"point"`
+		Ranges [][2]int   `hcl:"ranges"`
+		List   []string   `hcl:"list"`
+		Empty  [0]bool    `hcl:"empty"`
+		One    [1]string  `hcl:"one"`
+	}
+	schema, err := Schema(&config{})
+	require.NoError(t, err)
+	data, err := MarshalAST(schema)
+	require.NoError(t, err)
+	require.Equal(t, `pair = tuple([string, string])
+point = tuple([number, number, number])
+ranges = [tuple([number, number])]
+list = [string]
+empty = tuple([])
+one = tuple([string])
+`, string(data))
+
+	in := &config{Pair: [2]string{"a", "b"}, Ranges: [][2]int{{1, 2}}, List: []string{}, One: [1]string{"c"}}
+	data, err = Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, "pair = [\"a\", \"b\"]\npoint = [0, 0, 0]\nranges = [[1, 2]]\nlist = []\nempty = []\none = [\"c\"]\n", string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	err = Unmarshal([]byte("pair = [\"a\"]\npoint = [0, 0, 0]\nranges = []\nlist = []"), out)
+	require.EqualError(t, err, "1:8: attribute \"pair\": expected a list of 2 elements but got 1")
+}
+
+func TestSchemaPlaceholders(t *testing.T) {
+	type config struct {
+		Host  string            `hcl:"host" default:"localhost"`
+		Port  int               `hcl:"port,optional" default:"8080"`
+		Tags  []string          `hcl:"tags" default:"[\"web\"]"`
+		Debug bool              `hcl:"debug"`
+		Env   map[string]string `hcl:"env"`
+	}
+	schema, err := Schema(&config{}, SchemaSamples(true))
+	require.NoError(t, err)
+	data, err := MarshalAST(schema)
+	require.NoError(t, err)
+	require.Equal(t, `host = "..."
+port = 0 // (optional)
+tags = ["..."]
+debug = false
+env = {
+  "key": "...",
+}
+`, string(data))
+
+	schema, err = Schema(&config{}, SchemaDefaults(true))
+	require.NoError(t, err)
+	data, err = MarshalAST(schema)
+	require.NoError(t, err)
+	require.Equal(t, `host = "localhost"
+port = 8080 // (optional)
+tags = ["web"]
+debug = boolean
+env = {
+  string: string,
+}
+`, string(data))
+
+	type invalid struct {
+		Port int `hcl:"port" default:"{"`
+	}
+	_, err = Schema(&invalid{}, SchemaDefaults(true))
+	require.Error(t, err)
+}
+
+func TestSchemaValidationTag(t *testing.T) {
+	type tls struct {
+		Cert string `hcl:"cert" validate:"file"`
+	}
+	type config struct {
+		Port int    `hcl:"port,optional" help:"Port to listen on." validate:"min=1,max=65535"`
+		Host string `hcl:"host" validate:"hostname"`
+		Name string `hcl:"name"`
+		TLS  *tls   `hcl:"tls,block" validate:"required"`
+	}
+	schema, err := Schema(&config{}, SchemaValidationTag("validate"))
+	require.NoError(t, err)
+	data, err := MarshalAST(schema)
+	require.NoError(t, err)
+	require.Equal(t, `// Port to listen on.
+// min=1 max=65535
+port = number // (optional)
+// hostname
+host = string
+name = string
+
+// required
+tls {
+  // file
+  cert = string
+}
+`, string(data))
+
+	// Constraints are only documented in schemas.
+	data, err = Marshal(&config{Port: 80}, SchemaValidationTag("validate"))
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "min=1")
+}
+
+func TestCommentOptionalDefaults(t *testing.T) {
+	type server struct {
+		Name    string   `hcl:"name,label"`
+		Port    int      `hcl:"port,optional" default:"8080"`
+		Aliases []string `hcl:"aliases,optional" help:"Alternative host names."`
+	}
+	type config struct {
+		Host    string  `hcl:"host"`
+		Debug   bool    `hcl:"debug,optional"`
+		Servers server  `hcl:"server,block"`
+		Timeout float64 `hcl:"timeout"`
+	}
+	schema, err := Schema(&config{}, CommentOptionalDefaults(true))
+	require.NoError(t, err)
+	data, err := MarshalAST(schema)
+	require.NoError(t, err)
+	require.Equal(t, `host = string
+// debug = false
+
+server "name" {
+  // port = 8080
+  // Alternative host names.
+  // aliases = []
+}
+
+timeout = number
+`, string(data))
+
+	data, err = MarshalAST(schema, Compact(true))
+	require.NoError(t, err)
+	require.Equal(t, "host = string server \"name\" {} timeout = number", string(data))
+}
@@ -0,0 +1,234 @@
+package hcl
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/alecthomas/participle"
+	"github.com/alecthomas/participle/lexer"
+)
+
+// TypeKind is the kind of a TypeConstraint.
+type TypeKind int
+
+// Type constraint kinds.
+const (
+	TypeAny TypeKind = iota
+	TypeString
+	TypeNumber
+	TypeBool
+	TypeList
+	TypeSet
+	TypeMap
+	TypeObject
+	TypeTuple
+)
+
+func (k TypeKind) String() string {
+	switch k {
+	case TypeAny:
+		return "any"
+	case TypeString:
+		return "string"
+	case TypeNumber:
+		return "number"
+	case TypeBool:
+		return "bool"
+	case TypeList:
+		return "list"
+	case TypeSet:
+		return "set"
+	case TypeMap:
+		return "map"
+	case TypeObject:
+		return "object"
+	case TypeTuple:
+		return "tuple"
+	default:
+		return fmt.Sprintf("TypeKind(%d)", int(k))
+	}
+}
+
+// TypeConstraint is a parsed type-constraint expression such as "list(object({name=string}))".
+type TypeConstraint struct {
+	Kind TypeKind
+	// Element type for list, set and map constraints.
+	Elem *TypeConstraint
+	// Attributes of an object constraint, in declaration order.
+	Attrs []*TypeAttr
+	// Element types of a tuple constraint.
+	Elems []*TypeConstraint
+}
+
+// TypeAttr is a single named attribute of an object type constraint.
+type TypeAttr struct {
+	Name string
+	Type *TypeConstraint
+}
+
+func (t TypeConstraint) String() string {
+	switch t.Kind {
+	case TypeList, TypeSet, TypeMap:
+		return fmt.Sprintf("%s(%s)", t.Kind, t.Elem)
+
+	case TypeObject:
+		attrs := []string{}
+		for _, attr := range t.Attrs {
+			attrs = append(attrs, fmt.Sprintf("%s=%s", attr.Name, attr.Type))
+		}
+		return fmt.Sprintf("object({%s})", strings.Join(attrs, ", "))
+
+	case TypeTuple:
+		elems := []string{}
+		for _, elem := range t.Elems {
+			elems = append(elems, elem.String())
+		}
+		return fmt.Sprintf("tuple([%s])", strings.Join(elems, ", "))
+
+	default:
+		return t.Kind.String()
+	}
+}
+
+// Type returns a Go type that values matching the constraint can be unmarshalled into.
+//
+// Numbers map to float64, lists, sets and tuples to slices, maps to map[string]T,
+// objects to structs with hcl tags, and "any" to interface{}.
+func (t TypeConstraint) Type() reflect.Type {
+	switch t.Kind {
+	case TypeString:
+		return reflect.TypeOf("")
+
+	case TypeNumber:
+		return reflect.TypeOf(float64(0))
+
+	case TypeBool:
+		return reflect.TypeOf(false)
+
+	case TypeList, TypeSet:
+		return reflect.SliceOf(t.Elem.Type())
+
+	case TypeMap:
+		return reflect.MapOf(reflect.TypeOf(""), t.Elem.Type())
+
+	case TypeObject:
+		fields := []reflect.StructField{}
+		for i, attr := range t.Attrs {
+			fields = append(fields, reflect.StructField{
+				Name: fmt.Sprintf("F%d", i),
+				Type: attr.Type.Type(),
+				Tag:  reflect.StructTag(fmt.Sprintf("hcl:%q", attr.Name)),
+			})
+		}
+		return reflect.StructOf(fields)
+
+	case TypeTuple:
+		return reflect.TypeOf([]interface{}{})
+
+	default:
+		return reflect.TypeOf((*interface{})(nil)).Elem()
+	}
+}
+
+// ParseTypeConstraint parses a type-constraint expression such as "list(object({name=string}))".
+func ParseTypeConstraint(s string) (TypeConstraint, error) {
+	expr := &typeExpr{}
+	err := typeParser.ParseString(s, expr)
+	if err != nil {
+		return TypeConstraint{}, err
+	}
+	constraint, err := expr.constraint()
+	if err != nil {
+		return TypeConstraint{}, err
+	}
+	return *constraint, nil
+}
+
+var (
+	typeLexer  = lexer.Must(lexer.Regexp(`(?P<Ident>[[:alpha:]]\w*)|(?P<Punct>[(){}\[\]=,])|(\s+)`))
+	typeParser = participle.MustBuild(&typeExpr{},
+		participle.Lexer(typeLexer),
+		participle.UseLookahead(2))
+)
+
+type typeExpr struct {
+	Pos lexer.Position
+
+	Name   string         `parser:"@Ident"`
+	Open   bool           `parser:"( @'('"`
+	Object *typeObjectArg `parser:"  ( @@"`
+	Tuple  *typeTupleArg  `parser:"  | @@"`
+	Elem   *typeExpr      `parser:"  | @@ ) ')' )?"`
+}
+
+type typeObjectArg struct {
+	Attrs []*typeAttrExpr `parser:"'{' ( @@ ( ',' @@ )* ','? )? '}'"`
+}
+
+type typeAttrExpr struct {
+	Name string    `parser:"@Ident '='"`
+	Type *typeExpr `parser:"@@"`
+}
+
+type typeTupleArg struct {
+	Elems []*typeExpr `parser:"'[' ( @@ ( ',' @@ )* ','? )? ']'"`
+}
+
+func (e *typeExpr) constraint() (*TypeConstraint, error) {
+	switch e.Name {
+	case "any", "string", "number", "bool":
+		if e.Open {
+			return nil, participle.Errorf(e.Pos, "primitive type %q does not accept arguments", e.Name)
+		}
+		kind := map[string]TypeKind{"any": TypeAny, "string": TypeString, "number": TypeNumber, "bool": TypeBool}[e.Name]
+		return &TypeConstraint{Kind: kind}, nil
+
+	case "list", "set", "map":
+		if e.Elem == nil {
+			return nil, participle.Errorf(e.Pos, "%s type requires a single element type", e.Name)
+		}
+		elem, err := e.Elem.constraint()
+		if err != nil {
+			return nil, err
+		}
+		kind := map[string]TypeKind{"list": TypeList, "set": TypeSet, "map": TypeMap}[e.Name]
+		return &TypeConstraint{Kind: kind, Elem: elem}, nil
+
+	case "object":
+		if e.Object == nil {
+			return nil, participle.Errorf(e.Pos, "object type requires an attribute map such as object({name=string})")
+		}
+		out := &TypeConstraint{Kind: TypeObject}
+		seen := map[string]bool{}
+		for _, attr := range e.Object.Attrs {
+			if seen[attr.Name] {
+				return nil, participle.Errorf(attr.Type.Pos, "duplicate object attribute %q", attr.Name)
+			}
+			seen[attr.Name] = true
+			t, err := attr.Type.constraint()
+			if err != nil {
+				return nil, err
+			}
+			out.Attrs = append(out.Attrs, &TypeAttr{Name: attr.Name, Type: t})
+		}
+		return out, nil
+
+	case "tuple":
+		if e.Tuple == nil {
+			return nil, participle.Errorf(e.Pos, "tuple type requires a list of element types such as tuple([string, number])")
+		}
+		out := &TypeConstraint{Kind: TypeTuple}
+		for _, elem := range e.Tuple.Elems {
+			t, err := elem.constraint()
+			if err != nil {
+				return nil, err
+			}
+			out.Elems = append(out.Elems, t)
+		}
+		return out, nil
+
+	default:
+		return nil, participle.Errorf(e.Pos, "unknown type %q", e.Name)
+	}
+}
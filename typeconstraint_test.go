@@ -0,0 +1,56 @@
+package hcl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTypeConstraint(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		expected string
+		fail     string
+	}{
+		{name: "Primitive", src: "string", expected: "string"},
+		{name: "List", src: "list(number)", expected: "list(number)"},
+		{name: "NestedObject",
+			src:      "list(object({name=string, tags=map(string), ports=set(number)}))",
+			expected: "list(object({name=string, tags=map(string), ports=set(number)}))"},
+		{name: "Tuple", src: "tuple([string, bool])", expected: "tuple([string, bool])"},
+		{name: "UnknownType", src: "list(strin)", fail: `1:6: unknown type "strin"`},
+		{name: "MissingElement", src: "list", fail: `1:1: list type requires a single element type`},
+		{name: "PrimitiveWithArgs", src: "string(number)", fail: `1:1: primitive type "string" does not accept arguments`},
+		{name: "DuplicateAttribute", src: "object({a=string, a=number})", fail: `1:21: duplicate object attribute "a"`},
+		{name: "Unterminated", src: "list(string", fail: `1:12: unexpected token "<EOF>" (expected ")")`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			constraint, err := ParseTypeConstraint(test.src)
+			if test.fail != "" {
+				require.EqualError(t, err, test.fail)
+				require.Equal(t, TypeConstraint{}, constraint)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, constraint.String())
+		})
+	}
+}
+
+func TestTypeConstraintType(t *testing.T) {
+	constraint, err := ParseTypeConstraint("object({name=string, ports=list(number), tags=map(bool)})")
+	require.NoError(t, err)
+	rv := reflect.New(constraint.Type())
+	err = Unmarshal([]byte(`
+name = "web"
+ports = [80, 443]
+tags = {public: true}
+`), rv.Interface())
+	require.NoError(t, err)
+	require.Equal(t, "web", rv.Elem().Field(0).Interface())
+	require.Equal(t, []float64{80, 443}, rv.Elem().Field(1).Interface())
+	require.Equal(t, map[string]bool{"public": true}, rv.Elem().Field(2).Interface())
+}
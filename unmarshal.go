@@ -2,9 +2,12 @@ package hcl
 
 import (
 	"encoding"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"reflect"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,31 +17,75 @@ import (
 	"github.com/alecthomas/participle/lexer"
 )
 
+// Unmarshaler is implemented by types that decode themselves from HCL.
+//
+// "node" is the *Block, *Value or *AST the value is being decoded from. Unmarshaler takes precedence
+// over all other decoding except decode hooks and registered types. To decode the node with the
+// default behaviour from within UnmarshalHCL, convert the receiver to a type without the method,
+// eg. "type plain T", to avoid infinite recursion.
+type Unmarshaler interface {
+	UnmarshalHCL(node Node) error
+}
+
 var (
-	textUnmarshalerInterface = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
-	textMarshalerInterface   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
-	jsonUnmarshalerInterface = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
-	jsonMarshalerInterface   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
-	remainType               = reflect.TypeOf([]*Entry{})
-	durationType             = reflect.TypeOf(time.Duration(0))
-	timeType                 = reflect.TypeOf(time.Time{})
+	unmarshalerInterface       = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	textUnmarshalerInterface   = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	textMarshalerInterface     = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonUnmarshalerInterface   = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	jsonMarshalerInterface     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	binaryUnmarshalerInterface = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	binaryMarshalerInterface   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	errorInterface             = reflect.TypeOf((*error)(nil)).Elem()
+	stringerInterface          = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	isZeroerInterface          = reflect.TypeOf((*interface{ IsZero() bool })(nil)).Elem()
+	remainType                 = reflect.TypeOf([]*Entry{})
+	commentsType               = reflect.TypeOf([]string{})
+	durationType               = reflect.TypeOf(time.Duration(0))
+	timeType                   = reflect.TypeOf(time.Time{})
+	jsonNumberType             = reflect.TypeOf(json.Number(""))
+	bigFloatType               = reflect.TypeOf(big.Float{})
+	bigRatType                 = reflect.TypeOf(big.Rat{})
+	expressionType             = reflect.TypeOf(Expression(""))
 )
 
+// Warning is a non-fatal issue found while unmarshalling. See WithWarnings.
+type Warning struct {
+	Pos lexer.Position
+	// Key of the attribute or block the warning applies to.
+	Key     string
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", w.Pos, w.Message)
+}
+
 // Unmarshal HCL into a Go struct.
 func Unmarshal(data []byte, v interface{}, options ...MarshalOption) error {
-	ast, err := ParseBytes(data)
+	ast, err := parseWithOptions(data, newMarshalOptions(options...))
 	if err != nil {
 		return err
 	}
 	return UnmarshalAST(ast, v, options...)
 }
 
+// parseWithOptions parses HCL using the parser selected by the options.
+func parseWithOptions(data []byte, opt *marshalOptions) (*AST, error) {
+	if opt.allowColonAssignment {
+		return parseBytes(colonParser, data)
+	}
+	return ParseBytes(data)
+}
+
 // UnmarshalAST unmarshalls an already parsed or constructed AST into a Go struct.
 func UnmarshalAST(ast *AST, v interface{}, options ...MarshalOption) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr {
 		return fmt.Errorf("%T must be a pointer", v)
 	}
+	if uv, ok := implements(rv.Elem(), unmarshalerInterface); ok {
+		return uv.Interface().(Unmarshaler).UnmarshalHCL(ast)
+	}
 	opt := &marshalOptions{}
 	for _, option := range options {
 		option(opt)
@@ -67,6 +114,8 @@ func unmarshalEntries(v reflect.Value, entries []*Entry, opt *marshalOptions) er
 	// Collect entries from the source into a map.
 	seen := map[string]*Entry{}
 	mentries := make(map[string][]*Entry, len(entries))
+	// Comments of the first entry for each key, for "comments" fields.
+	siblingComments := map[string][]string{}
 	for _, entry := range entries {
 		key := entry.Key()
 		existing, ok := mentries[key]
@@ -78,21 +127,47 @@ func unmarshalEntries(v reflect.Value, entries []*Entry, opt *marshalOptions) er
 		}
 		mentries[key] = append(mentries[key], entry)
 		seen[key] = entry
+		if _, ok := siblingComments[key]; !ok {
+			siblingComments[key] = entryComments(entry)
+		}
+		if opt.warnings != nil {
+			collectDeprecations(entry, opt.warnings)
+		}
 	}
 	// Collect the fields of the target struct.
 	fields, err := flattenFields(v)
 	if err != nil {
 		return err
 	}
+	fields, err = promotedFields(v, fields, mentries, opt)
+	if err != nil {
+		return err
+	}
+	// Map receiving attributes that don't match other fields.
+	var inline *field
 	// Apply HCL entries to our fields.
 	for _, field := range fields {
-		tag := parseTag(v.Type(), field, opt) // nolint: govet
+		tag, err := parseTag(v.Type(), field, opt) // nolint: govet
+		if err != nil {
+			return err
+		}
 		switch {
+		case tag.commentsField:
+			if comments := siblingComments[tag.name]; tag.name != "" && len(comments) > 0 {
+				field.embed.allocate()
+				field.v.Set(reflect.ValueOf(append([]string(nil), comments...)))
+			}
+			continue
+
 		case tag.name == "":
 			continue
 
+		case tag.inline:
+			field := field
+			inline = &field
+			continue
+
 		case tag.label:
-			delete(seen, tag.name)
 			continue
 
 		case tag.remain:
@@ -110,22 +185,53 @@ func unmarshalEntries(v reflect.Value, entries []*Entry, opt *marshalOptions) er
 			return nil
 		}
 
+		if len(tag.aliases) > 0 {
+			if err := resolveAliases(tag, mentries, seen); err != nil {
+				return err
+			}
+		}
 		haventSeen := seen[tag.name] == nil
 		entries := mentries[tag.name]
 		if len(entries) == 0 {
-			if !tag.optional && haventSeen {
+			// Defaults only apply to absent keys, not explicit nulls.
+			if def, ok := field.t.Tag.Lookup("default"); ok && haventSeen {
+				value, err := parseDefault(field.t.Type, def)
+				if err == nil {
+					field.embed.allocate()
+					err = unmarshalValue(field.v, value, opt)
+				}
+				if err != nil {
+					return fmt.Errorf("invalid default for %s: %s", field.t.Name, err)
+				}
+				continue
+			}
+			// Fields of unallocated embedded pointer structs are implicitly optional.
+			if !tag.optional && haventSeen && field.embed == nil {
+				if tag.block {
+					return fmt.Errorf("missing required block %q", tag.name)
+				}
 				return fmt.Errorf("missing required attribute %q", tag.name)
 			}
 			continue
 		}
 		delete(seen, tag.name)
+		field.embed.allocate()
 
 		entry := entries[0]
 		entries = entries[1:]
 		mentries[tag.name] = entries
 
+		// An explicit null leaves the field as its zero value, eg. a nil slice or pointer.
+		if entry.Attribute != nil && entry.Attribute.Value.Null {
+			if len(entries) > 0 {
+				return participle.Errorf(entry.Pos, "duplicate field %q at %s", entry.Key(), entries[0].Pos)
+			}
+			field.v.Set(reflect.Zero(field.v.Type()))
+			continue
+		}
+
 		// Field is a pointer, create value if necessary, then move field down.
-		if field.v.Kind() == reflect.Ptr {
+		for field.v.Kind() == reflect.Ptr {
 			if field.v.IsNil() {
 				field.v.Set(reflect.New(field.v.Type().Elem()))
 			}
@@ -133,48 +239,82 @@ func unmarshalEntries(v reflect.Value, entries []*Entry, opt *marshalOptions) er
 			field.t.Type = field.t.Type.Elem()
 		}
 
-		// Check for unmarshaler interfaces and other special cases.
+		// Check for decode hooks, unmarshaler interfaces and other special cases.
+		var value *Value
 		if entry.Attribute != nil {
-			val := entry.Attribute.Value
-			if uv, ok := implements(field.v, jsonUnmarshalerInterface); ok {
-				err := uv.Interface().(json.Unmarshaler).UnmarshalJSON([]byte(val.String()))
+			value = entry.Attribute.Value
+			if tag.unit != "" {
+				value, err = stripUnit(value, tag.unit)
 				if err != nil {
-					return participle.Wrapf(val.Pos, err, "invalid value")
+					return err
 				}
-				continue
-			} else if uv, ok := implements(field.v, textUnmarshalerInterface); ok {
-				err := uv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(*val.Str))
+			}
+			if tag.lines {
+				value = splitLines(value)
+			}
+			if tag.convert != "" {
+				err = convertValue(field.v, value, converterRegistry[tag.convert])
 				if err != nil {
-					return participle.Wrapf(val.Pos, err, "invalid value")
+					return participle.Wrapf(value.Pos, err, "attribute %q", tag.name)
 				}
 				continue
-			} else if entry.Attribute.Value.Str != nil {
-				switch field.v.Interface().(type) {
-				case time.Duration:
-					d, err := time.ParseDuration(*val.Str)
-					if err != nil {
-						return participle.Wrapf(val.Pos, err, "invalid duration")
-					}
-					field.v.Set(reflect.ValueOf(d))
-					continue
+			}
+			var handled bool
+			handled, value, err = unmarshalSpecial(field.v, value, opt)
+			if err != nil {
+				return participle.Wrapf(entry.Attribute.Value.Pos, err, "attribute %q", tag.name)
+			}
+			if handled {
+				continue
+			}
+		}
 
-				case time.Time:
-					t, err := time.Parse(time.RFC3339, *val.Str)
-					if err != nil {
-						return participle.Wrapf(val.Pos, err, "invalid time")
-					}
-					field.v.Set(reflect.ValueOf(t))
-					continue
-				}
+		// A map tagged as a block absorbs the attributes of the block body.
+		if entry.Block != nil && tag.block && field.v.Kind() == reflect.Map {
+			if len(entries) > 0 {
+				return participle.Errorf(entry.Pos, "duplicate field %q at %s", entry.Key(), entries[0].Pos)
 			}
+			value, err := blockToMapValue(entry.Block)
+			if err != nil {
+				return err
+			}
+			err = unmarshalKind(field.v, value, opt)
+			if err != nil {
+				return participle.AnnotateError(entry.Pos, err)
+			}
+			continue
 		}
 
 		switch field.v.Kind() {
+		case reflect.Interface:
+			if len(entries) > 0 {
+				return participle.Errorf(entry.Pos, "duplicate field %q at %s", entry.Key(), entries[0].Pos)
+			}
+			if entry.Attribute != nil {
+				err = unmarshalKind(field.v, value, opt)
+				if err != nil {
+					return participle.AnnotateError(value.Pos, err)
+				}
+				continue
+			}
+			err := unmarshalInterfaceBlock(field.v, entry.Block, opt)
+			if err != nil {
+				return participle.AnnotateError(entry.Pos, err)
+			}
+
 		case reflect.Struct:
 			if len(entries) > 0 {
 				return participle.Errorf(entry.Pos, "duplicate field %q at %s", entry.Key(), entry.Pos)
 			}
 			if entry.Attribute != nil {
+				// Structs marshalled as values are maps.
+				if value.HaveMap {
+					err := unmarshalKind(field.v, value, opt)
+					if err != nil {
+						return participle.AnnotateError(value.Pos, err)
+					}
+					continue
+				}
 				return participle.Errorf(entry.Pos, "expected a block for %q but got an attribute", tag.name)
 			}
 			err := unmarshalBlock(field.v, entry.Block, opt)
@@ -191,22 +331,35 @@ func unmarshalEntries(v reflect.Value, entries []*Entry, opt *marshalOptions) er
 				ptr = true
 			}
 
-			if elt.Kind() == reflect.Struct {
+			// Lists of structs marshalled as values are decoded as attributes.
+			if elt.Kind() == reflect.Struct && !isValueType(elt) && entry.Block != nil {
 				mentries[field.t.Name] = nil
 				entries = append([]*Entry{entry}, entries...)
+				lv := resetSlice(field.v, len(entries), opt)
 				for _, entry := range entries {
 					if entry.Attribute != nil {
 						return participle.Errorf(entry.Pos, "expected a block for %q but got an attribute", tag.name)
 					}
-					el := reflect.New(elt).Elem()
+					var el reflect.Value
+					lv, el = growSlice(lv, opt)
+					if ptr {
+						if el.IsNil() {
+							el.Set(reflect.New(elt))
+						}
+						el = el.Elem()
+					}
 					err := unmarshalBlock(el, entry.Block, opt)
 					if err != nil {
 						return participle.AnnotateError(entry.Pos, err)
 					}
-					if ptr {
-						el = el.Addr()
-					}
-					field.v.Set(reflect.Append(field.v, el))
+				}
+				field.v.Set(lv)
+				continue
+			}
+			if opt.accumulateRepeatedAttributes && entry.Attribute != nil {
+				err := unmarshalRepeatedAttributes(field.v, value, entries, opt)
+				if err != nil {
+					return err
 				}
 				continue
 			}
@@ -220,14 +373,20 @@ func unmarshalEntries(v reflect.Value, entries []*Entry, opt *marshalOptions) er
 			if entry.Block != nil {
 				return participle.Errorf(entry.Pos, "expected an attribute for %q but got a block", tag.name)
 			}
-			value := entry.Attribute.Value
-			err = unmarshalValue(field.v, value)
+			err = unmarshalKind(field.v, value, opt)
 			if err != nil {
-				return participle.AnnotateError(value.Pos, err)
+				return participle.Wrapf(value.Pos, err, "attribute %q", tag.name)
 			}
 		}
 	}
 
+	if inline != nil {
+		err := unmarshalInline(*inline, seen, mentries, opt)
+		if err != nil {
+			return err
+		}
+	}
+
 	if len(seen) > 0 {
 		need := []string{}
 		var pos *lexer.Position
@@ -243,13 +402,30 @@ func unmarshalEntries(v reflect.Value, entries []*Entry, opt *marshalOptions) er
 }
 
 func unmarshalBlock(v reflect.Value, block *Block, opt *marshalOptions) error {
+	if uv, ok := implements(v, unmarshalerInterface); ok {
+		err := uv.Interface().(Unmarshaler).UnmarshalHCL(block)
+		if err != nil {
+			return participle.AnnotateError(block.Pos, err)
+		}
+		return nil
+	}
 	fields, err := flattenFields(v)
 	if err != nil {
 		return participle.AnnotateError(block.Pos, err)
 	}
 	labels := block.Labels
 	for _, field := range fields {
-		tag := parseTag(v.Type(), field, opt) // nolint: govet
+		tag, err := parseTag(v.Type(), field, opt) // nolint: govet
+		if err != nil {
+			return participle.AnnotateError(block.Pos, err)
+		}
+		if tag.commentsField && tag.name == "" {
+			if len(block.Comments) > 0 {
+				field.embed.allocate()
+				field.v.Set(reflect.ValueOf(append([]string(nil), block.Comments...)))
+			}
+			continue
+		}
 		if tag.name == "" || !tag.label {
 			continue
 		}
@@ -261,6 +437,7 @@ func unmarshalBlock(v reflect.Value, block *Block, opt *marshalOptions) error {
 		}
 		label := labels[0]
 		labels = labels[1:]
+		field.embed.allocate()
 		field.v.SetString(label)
 	}
 	if len(labels) > 0 {
@@ -269,44 +446,547 @@ func unmarshalBlock(v reflect.Value, block *Block, opt *marshalOptions) error {
 	return unmarshalEntries(v, block.Body, opt)
 }
 
-func unmarshalValue(rv reflect.Value, v *Value) error {
+// valueToNumber returns the number in a Value, parsing it from a string if necessary.
+func valueToNumber(v *Value) (*big.Float, error) {
+	switch {
+	case v.Number != nil:
+		return v.Number, nil
+	case v.Str != nil:
+		n, _, err := big.ParseFloat(*v.Str, 0, numberPrecision(len(*v.Str)), big.ToNearestEven)
+		if err != nil {
+			return nil, participle.Wrapf(v.Pos, err, "invalid number")
+		}
+		return n, nil
+	default:
+		return nil, typeMismatch(v, "number")
+	}
+}
+
+var jsonNumberRe = regexp.MustCompile(`^-?(?:0|[1-9][0-9]*)(?:\.[0-9]+)?(?:[eE][-+]?[0-9]+)?$`)
+
+// jsonNumberText returns the text of a number for a json.Number, preferring the literal as written
+// in the source, eg. "1.10", if it is valid JSON.
+func jsonNumberText(v *Value, n *big.Float) string {
+	if v.Number == n && jsonNumberRe.MatchString(v.Literal) {
+		if literal, _, err := big.ParseFloat(v.Literal, 10, n.Prec(), big.ToNearestEven); err == nil && literal.Cmp(n) == 0 {
+			return v.Literal
+		}
+	}
+	return formatNumber(n)
+}
+
+// typeMismatch returns an error describing a value that is not of the expected kind.
+func typeMismatch(v *Value, expected string) error {
+	return participle.Errorf(v.Pos, "expected %s, got %s", expected, describeValue(v))
+}
+
+// describeValue describes the kind and content of a value, eg. `string "abc"`.
+func describeValue(v *Value) string {
+	switch {
+	case v.Null:
+		return "null"
+	case v.Bool != nil:
+		return "bool " + v.String()
+	case v.Number != nil:
+		return "number " + v.String()
+	case v.Type != nil:
+		return "type " + v.String()
+	case v.Str != nil:
+		return "string " + strconv.Quote(*v.Str)
+	case v.HeredocDelimiter != "":
+		return "heredoc"
+	case v.Expr != nil:
+		return "expression " + v.String()
+	case v.HaveList:
+		return "list " + v.String()
+	case v.HaveMap:
+		return "map " + v.String()
+	default:
+		return v.String()
+	}
+}
+
+// collectDeprecations appends a warning for each "DEPRECATED" comment preceding an entry.
+func collectDeprecations(entry *Entry, warnings *[]Warning) {
+	for _, comment := range entryComments(entry) {
+		text := strings.TrimLeft(comment, "# \t")
+		if !strings.HasPrefix(text, "DEPRECATED") {
+			continue
+		}
+		message := fmt.Sprintf("%q is deprecated", entry.Key())
+		if detail := strings.TrimLeft(strings.TrimPrefix(text, "DEPRECATED"), ":- \t"); detail != "" {
+			message += ": " + detail
+		}
+		*warnings = append(*warnings, Warning{Pos: entry.Pos, Key: entry.Key(), Message: message})
+	}
+}
+
+// unmarshalInline decodes attributes that didn't match any other field into the inline map field.
+//
+// Decoded attributes are removed from "seen". Blocks are left in place.
+func unmarshalInline(f field, seen map[string]*Entry, mentries map[string][]*Entry, opt *marshalOptions) error {
+	t := f.v.Type()
+	for key := range seen {
+		entries := mentries[key]
+		if entries[0].Attribute == nil {
+			continue
+		}
+		if len(entries) > 1 {
+			return participle.Errorf(entries[0].Pos, "duplicate field %q at %s", key, entries[1].Pos)
+		}
+		if f.v.IsNil() {
+			f.embed.allocate()
+			f.v.Set(reflect.MakeMap(t))
+		}
+		value := reflect.New(t.Elem()).Elem()
+		err := unmarshalValue(value, entries[0].Attribute.Value, opt)
+		if err != nil {
+			return participle.AnnotateError(entries[0].Pos, err)
+		}
+		f.v.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), value)
+		delete(seen, key)
+	}
+	return nil
+}
+
+// stripUnit converts strings consisting of a number followed by "unit", eg. "30s", into numbers.
+//
+// The unit must match exactly and immediately follow the number. Bare numbers are accepted as-is,
+// while any other string is an error, eg. "30ms" for the unit "s". Lists are converted element-wise.
+func stripUnit(v *Value, unit string) (*Value, error) {
+	switch {
+	case v.HaveList:
+		out := *v
+		out.List = make([]*Value, len(v.List))
+		for i, el := range v.List {
+			converted, err := stripUnit(el, unit)
+			if err != nil {
+				return nil, err
+			}
+			out.List[i] = converted
+		}
+		return &out, nil
+
+	case v.Str != nil:
+		text := strings.TrimSuffix(*v.Str, unit)
+		if text == *v.Str || text == "" || numberRe.FindString(text) != text {
+			return nil, participle.Errorf(v.Pos, "expected a number with unit %q but got %s", unit, v)
+		}
+		n, _, err := big.ParseFloat(text, 0, numberPrecision(len(text)), big.ToNearestEven)
+		if err != nil {
+			return nil, participle.Wrapf(v.Pos, err, "invalid number")
+		}
+		return &Value{Pos: v.Pos, Parent: v.Parent, Number: n}, nil
+
+	default:
+		return v, nil
+	}
+}
+
+// splitLines converts a heredoc or string into a list with one string per line.
+//
+// An empty string is an empty list, and other values are returned unchanged.
+func splitLines(v *Value) *Value {
+	var text string
+	switch {
+	case v.HeredocDelimiter != "":
+		// Heredocs are verbatim, so escape templates to cancel out their unescaping in each line.
+		text = escapeTemplates(v.GetHeredoc())
+	case v.Str != nil:
+		text = *v.Str
+	default:
+		return v
+	}
+	out := &Value{Pos: v.Pos, Parent: v.Parent, HaveList: true}
+	if text == "" {
+		return out
+	}
+	for _, line := range strings.Split(text, "\n") {
+		line := line
+		out.List = append(out.List, &Value{Pos: v.Pos, Parent: out, Str: &line})
+	}
+	return out
+}
+
+// isNumberKind returns true for integer and floating point kinds.
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func entryComments(entry *Entry) []string {
+	if entry.Block != nil {
+		return entry.Block.Comments
+	}
+	return entry.Attribute.Comments
+}
+
+// mapValueToEntries converts the entries of a map value into attributes.
+func mapValueToEntries(v *Value) ([]*Entry, error) {
+	entries := make([]*Entry, 0, len(v.Map))
+	for _, entry := range v.Map {
+		var key string
+		switch {
+		case entry.Key.Str != nil:
+			key = *entry.Key.Str
+		case entry.Key.Type != nil:
+			key = *entry.Key.Type
+		default:
+			return nil, participle.Errorf(entry.Key.Pos, "map key must be a string or type but is %s", entry.Key)
+		}
+		entries = append(entries, &Entry{
+			Pos:       entry.Pos,
+			Attribute: &Attribute{Pos: entry.Pos, Key: key, Value: entry.Value},
+		})
+	}
+	return entries, nil
+}
+
+// unmarshalRepeatedAttributes decodes the values of repeated attributes into a single slice.
+//
+// "first" is the value of the first attribute, while "rest" are the remaining entries. Values
+// that are lists are concatenated.
+func unmarshalRepeatedAttributes(rv reflect.Value, first *Value, rest []*Entry, opt *marshalOptions) error {
+	list := &Value{Pos: first.Pos, HaveList: true, List: []*Value{}}
+	values := []*Value{first}
+	for _, entry := range rest {
+		if entry.Block != nil {
+			return participle.Errorf(entry.Pos, "expected an attribute for %q but got a block", entry.Key())
+		}
+		values = append(values, entry.Attribute.Value)
+	}
+	for _, value := range values {
+		if value.HaveList {
+			list.List = append(list.List, value.List...)
+		} else {
+			list.List = append(list.List, value)
+		}
+	}
+	return unmarshalKind(rv, list, opt)
+}
+
+// blockToMapValue converts the attributes of a block into a map value.
+func blockToMapValue(block *Block) (*Value, error) {
+	if len(block.Labels) > 0 {
+		return nil, participle.Errorf(block.Pos, "block %q decoded as a map cannot have labels", block.Name)
+	}
+	value := &Value{Pos: block.Pos, HaveMap: true, Map: []*MapEntry{}}
+	for _, entry := range block.Body {
+		if entry.Block != nil {
+			return nil, participle.Errorf(entry.Pos, "block %q decoded as a map cannot contain block %q", block.Name, entry.Block.Name)
+		}
+		key := entry.Attribute.Key
+		value.Map = append(value.Map, &MapEntry{
+			Pos:   entry.Pos,
+			Key:   &Value{Pos: entry.Pos, Str: &key},
+			Value: entry.Attribute.Value,
+		})
+	}
+	return value, nil
+}
+
+// resolveAliases moves entries keyed by an alias of a field to the field's canonical name.
+//
+// Only one of the canonical name and its aliases may be present.
+func resolveAliases(tag tag, mentries map[string][]*Entry, seen map[string]*Entry) error {
+	found := ""
+	for _, key := range append([]string{tag.name}, tag.aliases...) {
+		if len(mentries[key]) == 0 {
+			continue
+		}
+		if found != "" {
+			return participle.Errorf(mentries[key][0].Pos, "%q and %q are aliases of the same field, only one may be set", found, key)
+		}
+		found = key
+	}
+	if found == "" || found == tag.name {
+		return nil
+	}
+	mentries[tag.name] = mentries[found]
+	delete(mentries, found)
+	if entry, ok := seen[found]; ok {
+		seen[tag.name] = entry
+		delete(seen, found)
+	}
+	return nil
+}
+
+// convertValue decodes v into rv with a registered converter.
+func convertValue(rv reflect.Value, v *Value, convert UnmarshalFunc) error {
+	if v.Null {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	err := convert(v, rv)
+	if err != nil {
+		return participle.Wrapf(v.Pos, err, "invalid value")
+	}
+	return nil
+}
+
+func unmarshalValue(rv reflect.Value, v *Value, opt *marshalOptions) error {
+	if v.Null {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			pv := reflect.New(rv.Type().Elem())
+			rv.Set(pv)
+		}
+		return unmarshalValue(rv.Elem(), v, opt)
+	}
+	handled, v, err := unmarshalSpecial(rv, v, opt)
+	if err != nil || handled {
+		return err
+	}
+	return unmarshalKind(rv, v, opt)
+}
+
+// unmarshalSpecial applies decode hooks, unmarshaler interfaces and other special cased types.
+//
+// If the value was not handled, the (possibly hook-transformed) value is returned.
+func unmarshalSpecial(rv reflect.Value, v *Value, opt *marshalOptions) (bool, *Value, error) {
+	for _, hook := range opt.decodeHooks {
+		out, err := hook(valueType(v), rv.Type(), v)
+		if err != nil {
+			return false, nil, participle.Wrapf(v.Pos, err, "invalid value")
+		}
+		switch out := out.(type) {
+		case nil:
+
+		case *Value:
+			v = out
+
+		default:
+			ov := reflect.ValueOf(out)
+			if !ov.Type().AssignableTo(rv.Type()) {
+				if !ov.Type().ConvertibleTo(rv.Type()) {
+					return false, nil, participle.Errorf(v.Pos, "decode hook returned %s but expected %s", ov.Type(), rv.Type())
+				}
+				ov = ov.Convert(rv.Type())
+			}
+			rv.Set(ov)
+			return true, v, nil
+		}
+	}
+	if rv.Type() == expressionType {
+		rv.SetString(v.String())
+		return true, v, nil
+	} else if v.Expr != nil {
+		return false, nil, participle.Errorf(v.Pos, "expressions can only be unmarshalled into hcl.Expression, not %s", rv.Type())
+	} else if registered, ok := typeRegistry[rv.Type()]; ok && registered.unmarshal != nil {
+		err := registered.unmarshal(v, rv)
+		if err != nil {
+			return false, nil, participle.Wrapf(v.Pos, err, "invalid value")
+		}
+		return true, v, nil
+	} else if uv, ok := implements(rv, unmarshalerInterface); ok {
+		err := uv.Interface().(Unmarshaler).UnmarshalHCL(v)
+		if err != nil {
+			return false, nil, participle.Wrapf(v.Pos, err, "invalid value")
+		}
+		return true, v, nil
+	} else if v.Str != nil && (rv.Type() == durationType || rv.Type() == timeType) {
+		switch rv.Interface().(type) {
+		case time.Duration:
+			d, err := time.ParseDuration(*v.Str)
+			if err != nil {
+				return false, nil, participle.Wrapf(v.Pos, err, "invalid duration")
+			}
+			rv.Set(reflect.ValueOf(d))
+			return true, v, nil
+
+		case time.Time:
+			t, err := parseTime(*v.Str, opt)
+			if err != nil {
+				return false, nil, participle.Wrapf(v.Pos, err, "invalid time")
+			}
+			rv.Set(reflect.ValueOf(t))
+			return true, v, nil
+		}
+	} else if rv.Type() == jsonNumberType {
+		n, err := valueToNumber(v)
+		if err != nil {
+			return false, nil, err
+		}
+		rv.SetString(jsonNumberText(v, n))
+		return true, v, nil
+	} else if rv.Type() == bigFloatType {
+		n, err := valueToNumber(v)
+		if err != nil {
+			return false, nil, err
+		}
+		f := new(big.Float).Copy(n)
+		rv.Set(reflect.ValueOf(f).Elem())
+		return true, v, nil
+	} else if rv.Type() == bigRatType {
+		// Fractions such as "1/3" are strings, while decimals may also be number literals.
+		var text string
+		switch {
+		case v.Number != nil:
+			text = formatNumber(v.Number)
+		case v.Str != nil:
+			text = *v.Str
+		default:
+			return false, nil, typeMismatch(v, "fraction or number")
+		}
+		r, ok := new(big.Rat).SetString(text)
+		if !ok {
+			return false, nil, participle.Errorf(v.Pos, "invalid fraction or decimal %q", text)
+		}
+		rv.Set(reflect.ValueOf(r).Elem())
+		return true, v, nil
+	} else if uv, ok := implements(rv, jsonUnmarshalerInterface); ok {
+		err := uv.Interface().(json.Unmarshaler).UnmarshalJSON([]byte(v.String()))
+		if err != nil {
+			return false, nil, participle.Wrapf(v.Pos, err, "invalid value")
+		}
+		return true, v, nil
+	} else if uv, ok := implements(rv, textUnmarshalerInterface); ok {
+		if v.Str == nil {
+			return false, nil, typeMismatch(v, "string")
+		}
+		err := uv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(*v.Str))
+		if err != nil {
+			return false, nil, participle.Wrapf(v.Pos, err, "invalid value")
+		}
+		return true, v, nil
+	} else if uv, ok := implements(rv, binaryUnmarshalerInterface); ok {
+		if v.Str == nil {
+			return false, nil, typeMismatch(v, "base64 encoded string")
+		}
+		data, err := base64.StdEncoding.DecodeString(*v.Str)
+		if err != nil {
+			return false, nil, participle.Wrapf(v.Pos, err, "invalid base64 value")
+		}
+		err = uv.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data)
+		if err != nil {
+			return false, nil, participle.Wrapf(v.Pos, err, "invalid value")
+		}
+		return true, v, nil
+	}
+	return false, v, nil
+}
+
+// parseTime parses a time using the first matching layout from TimeLayouts(), defaulting to RFC3339.
+func parseTime(s string, opt *marshalOptions) (time.Time, error) {
+	layouts := opt.timeLayouts
+	if len(layouts) == 0 {
+		layouts = []string{time.RFC3339}
+	}
+	var firstErr error
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return time.Time{}, firstErr
+}
+
+// isValueType returns true if values of struct type "t" are decoded from attribute values rather than blocks.
+func isValueType(t reflect.Type) bool {
+	if registered, ok := typeRegistry[t]; ok && registered.unmarshal != nil {
+		return true
+	}
+	return t == timeType || typeImplements(t, textUnmarshalerInterface) || typeImplements(t, jsonUnmarshalerInterface) ||
+		typeImplements(t, binaryUnmarshalerInterface)
+}
+
+// unquoteScalar converts a quoted number or bool, as emitted by QuoteAll(true), back to its unquoted form.
+func unquoteScalar(kind reflect.Kind, v *Value) *Value {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		n, _, err := big.ParseFloat(*v.Str, 10, 64, big.ToNearestEven)
+		if err != nil {
+			return v
+		}
+		return &Value{Pos: v.Pos, Parent: v.Parent, Number: n}
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(*v.Str)
+		if err != nil {
+			return v
+		}
+		return &Value{Pos: v.Pos, Parent: v.Parent, Bool: (*Bool)(&b)}
+	}
+	return v
+}
+
+// valueType returns the Go type a Value would naturally decode into.
+func valueType(v *Value) reflect.Type {
+	switch {
+	case v.Bool != nil:
+		return reflect.TypeOf(false)
+	case v.Number != nil:
+		return reflect.TypeOf(float64(0))
+	case v.HaveList:
+		return reflect.TypeOf([]interface{}{})
+	case v.HaveMap:
+		return reflect.TypeOf(map[string]interface{}{})
+	default:
+		return reflect.TypeOf("")
+	}
+}
+
+func unmarshalKind(rv reflect.Value, v *Value, opt *marshalOptions) error {
+	if opt.quoteAll && v.Str != nil {
+		v = unquoteScalar(rv.Kind(), v)
+	}
 	switch rv.Kind() {
 	case reflect.String:
 		switch {
 		case v.Str != nil:
-			rv.SetString(*v.Str)
+			rv.SetString(unescapeTemplates(*v.Str))
 		case v.Type != nil:
 			rv.SetString(*v.Type)
 		case v.HeredocDelimiter != "":
 			rv.SetString(v.GetHeredoc())
 		default:
-			return participle.Errorf(v.Pos, "expected a type or string but got %s", v)
+			return typeMismatch(v, "string")
 		}
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		if v.Number == nil {
-			return participle.Errorf(v.Pos, "expected a number but got %s", v)
+			return typeMismatch(v, "number")
 		}
 		n, _ := v.Number.Int64()
 		rv.SetInt(n)
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		if v.Number == nil {
-			return participle.Errorf(v.Pos, "expected a number but got %s", v)
+			return typeMismatch(v, "number")
 		}
 		n, _ := v.Number.Uint64()
 		rv.SetUint(n)
 
 	case reflect.Float32, reflect.Float64:
 		if v.Number == nil {
-			return participle.Errorf(v.Pos, "expected a number but got %s", v)
+			return typeMismatch(v, "number")
 		}
 		n, _ := v.Number.Float64()
 		rv.SetFloat(n)
 
 	case reflect.Map:
 		if !v.HaveMap {
-			return participle.Errorf(v.Pos, "expected a map but got %s", v)
+			return typeMismatch(v, "map")
 		}
 		t := rv.Type()
 		if t.Key().Kind() != reflect.String {
@@ -324,7 +1004,7 @@ func unmarshalValue(rv reflect.Value, v *Value) error {
 			default:
 				panic(fmt.Errorf("map key must be a string or type but is %s", entry.Key))
 			}
-			err := unmarshalValue(value, entry.Value)
+			err := unmarshalValue(value, entry.Value, opt)
 			if err != nil {
 				return participle.Wrapf(entry.Value.Pos, err, "invalid map value")
 			}
@@ -333,61 +1013,248 @@ func unmarshalValue(rv reflect.Value, v *Value) error {
 
 	case reflect.Slice:
 		if !v.HaveList {
-			return fmt.Errorf("expected a list but got %s", v)
+			return typeMismatch(v, "list")
 		}
-		t := rv.Type().Elem()
-		lv := reflect.MakeSlice(rv.Type(), 0, 4)
+		lv := resetSlice(rv, len(v.List), opt)
 		for _, entry := range v.List {
-			value := reflect.New(t).Elem()
-			err := unmarshalValue(value, entry)
+			var value reflect.Value
+			lv, value = growSlice(lv, opt)
+			err := unmarshalValue(value, entry, opt)
 			if err != nil {
 				return participle.Wrapf(entry.Pos, err, "invalid list element")
 			}
-			lv = reflect.Append(lv, value)
 		}
 		rv.Set(lv)
 
-	case reflect.Ptr:
-		if rv.IsNil() {
-			pv := reflect.New(rv.Type().Elem())
-			rv.Set(pv)
+	case reflect.Array:
+		if !v.HaveList {
+			return typeMismatch(v, "list")
+		}
+		if len(v.List) != rv.Len() {
+			return participle.Errorf(v.Pos, "expected a list of %d elements but got %d", rv.Len(), len(v.List))
+		}
+		for i, entry := range v.List {
+			err := unmarshalValue(rv.Index(i), entry, opt)
+			if err != nil {
+				return participle.Wrapf(entry.Pos, err, "invalid list element")
+			}
 		}
-		return unmarshalValue(rv.Elem(), v)
 
 	case reflect.Bool:
 		if v.Bool == nil {
-			return participle.Errorf(v.Pos, "expected a bool but got %s", v)
+			return typeMismatch(v, "bool")
 		}
 		rv.SetBool(bool(*v.Bool))
 
+	case reflect.Struct:
+		if !v.HaveMap {
+			return typeMismatch(v, "map")
+		}
+		entries, err := mapValueToEntries(v)
+		if err != nil {
+			return err
+		}
+		return unmarshalEntries(rv, entries, opt)
+
+	case reflect.Interface:
+		target, err := newInterfaceValue(rv.Type(), valueType(v), opt)
+		if err != nil {
+			return participle.Wrapf(v.Pos, err, "invalid value")
+		}
+		err = unmarshalValue(target, v, opt)
+		if err != nil {
+			return err
+		}
+		rv.Set(target)
+
 	default:
 		panic(rv.Kind().String())
 	}
 	return nil
 }
 
+// resetSlice returns an empty slice to decode n elements into, in place of the slice rv.
+//
+// A new slice is allocated unless ReuseSlices(true) is set, in which case the existing backing array is reused.
+func resetSlice(rv reflect.Value, n int, opt *marshalOptions) reflect.Value {
+	if opt.reuseSlices && !rv.IsNil() {
+		return rv.Slice(0, 0)
+	}
+	return reflect.MakeSlice(rv.Type(), 0, n)
+}
+
+// growSlice extends a slice by one element, returning the new slice and the element to decode into.
+//
+// With ReuseSlices(true), elements within the capacity of the slice are reused as-is.
+func growSlice(lv reflect.Value, opt *marshalOptions) (reflect.Value, reflect.Value) {
+	n := lv.Len()
+	if opt.reuseSlices && n < lv.Cap() {
+		lv = lv.Slice(0, n+1)
+		return lv, lv.Index(n)
+	}
+	lv = reflect.Append(lv, reflect.Zero(lv.Type().Elem()))
+	return lv, lv.Index(n)
+}
+
+// newInterfaceValue allocates a value to decode into for the interface type "iface".
+//
+// The default registered with WithInterfaceDefault is used if present, otherwise empty interfaces
+// use "natural", the Go type the HCL value would naturally decode into.
+func newInterfaceValue(iface reflect.Type, natural reflect.Type, opt *marshalOptions) (reflect.Value, error) {
+	if concrete, ok := opt.interfaceDefaults[iface]; ok {
+		return reflect.New(concrete).Elem(), nil
+	}
+	if iface.NumMethod() == 0 {
+		return reflect.New(natural).Elem(), nil
+	}
+	return reflect.Value{}, fmt.Errorf("no default type for interface %s, see WithInterfaceDefault", iface)
+}
+
+// unmarshalInterfaceBlock decodes a block into a field of interface type.
+func unmarshalInterfaceBlock(rv reflect.Value, block *Block, opt *marshalOptions) error {
+	target, err := newInterfaceValue(rv.Type(), reflect.TypeOf(map[string]interface{}{}), opt)
+	if err != nil {
+		return participle.AnnotateError(block.Pos, err)
+	}
+	sv := target
+	if sv.Kind() == reflect.Ptr {
+		sv.Set(reflect.New(sv.Type().Elem()))
+		sv = sv.Elem()
+	}
+	switch sv.Kind() {
+	case reflect.Struct:
+		err = unmarshalBlock(sv, block, opt)
+	case reflect.Map:
+		var value *Value
+		value, err = blockToMapValue(block)
+		if err == nil {
+			err = unmarshalKind(sv, value, opt)
+		}
+	default:
+		return participle.Errorf(block.Pos, "can't decode block %q into %s", block.Name, target.Type())
+	}
+	if err != nil {
+		return err
+	}
+	rv.Set(target)
+	return nil
+}
+
 type field struct {
 	t reflect.StructField
 	v reflect.Value
+	// Non-nil if the field is promoted from an embedded pointer struct that has not been allocated.
+	embed *embeddedPtr
+	// Depth of embedding, 0 for fields declared directly on the struct.
+	depth int
+}
+
+// embeddedPtr is a lazily allocated embedded pointer struct.
+type embeddedPtr struct {
+	ptr    reflect.Value
+	value  reflect.Value
+	parent *embeddedPtr
+}
+
+// allocate the embedded struct, and any embedded structs it is promoted through.
+func (e *embeddedPtr) allocate() {
+	if e == nil {
+		return
+	}
+	e.parent.allocate()
+	if e.ptr.IsNil() {
+		e.ptr.Set(e.value)
+	}
+}
+
+// indirectKind returns the kind of t, or of its element if t is a pointer.
+func indirectKind(t reflect.Type) reflect.Kind {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem().Kind()
+	}
+	return t.Kind()
 }
 
 func flattenFields(v reflect.Value) ([]field, error) {
+	return flattenEmbeddedFields(v, nil, 0)
+}
+
+func flattenEmbeddedFields(v reflect.Value, embed *embeddedPtr, depth int) ([]field, error) {
 	out := []field{}
 	t := v.Type()
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Field(i)
 		ft := t.Field(i)
-		if ft.Anonymous {
+		if ft.Anonymous && ft.PkgPath != "" && indirectKind(ft.Type) != reflect.Struct {
+			// As with encoding/json, unexported embedded non-struct types are skipped.
+			continue
+		} else if ft.Anonymous {
+			// Exported fields of embedded structs are promoted, even if the embedded type is unexported.
+			subEmbed := embed
+			if f.Kind() == reflect.Ptr && f.Type().Elem().Kind() == reflect.Struct {
+				if f.IsNil() {
+					if !f.CanSet() {
+						return nil, fmt.Errorf("%s: unexported embedded pointer can't be allocated", ft.Name)
+					}
+					// Fields are decoded into a detached value, which is only attached if used.
+					subEmbed = &embeddedPtr{ptr: f, value: reflect.New(f.Type().Elem()), parent: embed}
+					f = subEmbed.value
+				}
+				f = f.Elem()
+			}
 			if f.Kind() != reflect.Struct {
 				return nil, fmt.Errorf("%s: anonymous field must be a struct", ft.Name)
 			}
-			sub, err := flattenFields(f)
+			sub, err := flattenEmbeddedFields(f, subEmbed, depth+1)
 			if err != nil {
 				return nil, fmt.Errorf("%s: %s", ft.Name, err)
 			}
 			out = append(out, sub...)
-		} else {
-			out = append(out, field{ft, f})
+		} else if ft.PkgPath == "" {
+			// Unexported fields can't be accessed via reflection, so are skipped.
+			out = append(out, field{t: ft, v: f, embed: embed, depth: depth})
+		}
+	}
+	return out, nil
+}
+
+// promotedFields resolves fields promoted from embedded structs as Go does: fields at a shallower
+// depth shadow deeper ones, while fields with the same name at the same depth are ambiguous.
+//
+// As with Go, ambiguity is only an error if the field is actually used, ie. present in entries.
+func promotedFields(v reflect.Value, fields []field, entries map[string][]*Entry, opt *marshalOptions) ([]field, error) {
+	depths := map[string]int{}
+	ambiguous := map[string]bool{}
+	for _, field := range fields {
+		tag, err := parseTag(v.Type(), field, opt)
+		if err != nil {
+			return nil, err
+		}
+		// Labels don't share a namespace with attributes and blocks.
+		if tag.name == "" || tag.commentsField || tag.label {
+			continue
+		}
+		if depth, ok := depths[tag.name]; ok && depth <= field.depth {
+			if depth == field.depth {
+				ambiguous[tag.name] = true
+			}
+			continue
+		}
+		depths[tag.name] = field.depth
+		delete(ambiguous, tag.name)
+	}
+	out := make([]field, 0, len(fields))
+	for _, field := range fields {
+		tag, _ := parseTag(v.Type(), field, opt)
+		if tag.name == "" || tag.commentsField || tag.label {
+			out = append(out, field)
+			continue
+		}
+		if ambiguous[tag.name] && len(entries[tag.name]) > 0 {
+			return nil, participle.Errorf(entries[tag.name][0].Pos, "ambiguous field %q in %s", tag.name, v.Type())
+		}
+		if depths[tag.name] == field.depth {
+			out = append(out, field)
 		}
 	}
 	return out, nil
@@ -404,6 +1271,27 @@ type tag struct {
 	block    bool
 	remain   bool
 	help     string
+	radix    int
+	// Marshal the string as a heredoc.
+	multiline bool
+	// Group of attributes to cluster together when marshalling.
+	group string
+	// Marshal the string as a bare identifier.
+	ident bool
+	// Omit the field when marshalling if it is the zero value.
+	omitZero bool
+	// Unit suffix of numbers marshalled as strings, eg. "s" for "30s".
+	unit string
+	// Entries of the map are attributes of the enclosing block.
+	inline bool
+	// Marshal the []string as a heredoc with one element per line.
+	lines bool
+	// Name of the converter used to unmarshal the value, see RegisterConverter.
+	convert string
+	// Alternative names accepted when unmarshalling.
+	aliases []string
+	// Field receives comments. Name is the sibling entry the comments are attached to, or empty for the enclosing block.
+	commentsField bool
 }
 
 func (t tag) comments() []string {
@@ -413,7 +1301,72 @@ func (t tag) comments() []string {
 	return nil
 }
 
-func parseTag(parent reflect.Type, f field, opt *marshalOptions) tag {
+// FieldTag describes how a Go struct field maps to HCL.
+type FieldTag struct {
+	// Field is the name of the Go struct field.
+	Field string
+	// Name of the HCL attribute, block or label.
+	Name     string
+	Optional bool
+	Label    bool
+	Block    bool
+	Remain   bool
+	Help     string
+}
+
+// ParseStructTags returns how each field of a Go struct maps to HCL.
+//
+// "v" must be a struct or a pointer to a struct. Promoted fields of embedded
+// structs are included, while fields tagged with "-" are not.
+func ParseStructTags(v interface{}, options ...MarshalOption) ([]FieldTag, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a struct or a pointer to a struct, not %T", v)
+	}
+	rv := reflect.New(t).Elem()
+	fields, err := flattenFields(rv)
+	if err != nil {
+		return nil, err
+	}
+	opt := newMarshalOptions(options...)
+	out := []FieldTag{}
+	for _, field := range fields {
+		tag, err := parseTag(t, field, opt)
+		if err != nil {
+			return nil, err
+		}
+		if tag.name == "" {
+			continue
+		}
+		out = append(out, FieldTag{
+			Field:    field.t.Name,
+			Name:     tag.name,
+			Optional: tag.optional,
+			Label:    tag.label,
+			Block:    tag.block,
+			Remain:   tag.remain,
+			Help:     tag.help,
+		})
+	}
+	return out, nil
+}
+
+// fieldKey derives the key of a field from its Go name.
+func fieldKey(name string, opt *marshalOptions) string {
+	switch opt.keyCase {
+	case KeyCaseLower:
+		return strings.ToLower(name)
+	case KeyCaseSnake:
+		return snakeCase(name)
+	default:
+		return name
+	}
+}
+
+func parseTag(parent reflect.Type, f field, opt *marshalOptions) (tag, error) {
 	t := f.t
 	help := t.Tag.Get("help")
 	s, ok := t.Tag.Lookup("hcl")
@@ -428,37 +1381,264 @@ func parseTag(parent reflect.Type, f field, opt *marshalOptions) tag {
 		isBlock = tt.Kind() == reflect.Struct
 	}
 
+	// Untagged fields and blocks are implicitly optional unless RequireAllNonOptional() is set.
+	implicitlyOptional := !opt.requireAllNonOptional || t.Type.Kind() == reflect.Ptr
+
+	fromJSON := false
 	if !ok {
-		s, ok = t.Tag.Lookup("json")
-		if !ok {
-			return tag{name: t.Name, block: isBlock, optional: true, help: help}
+		s, fromJSON = t.Tag.Lookup("json")
+		if !fromJSON {
+			return tag{name: fieldKey(t.Name, opt), block: isBlock, optional: implicitlyOptional, help: help}, nil
 		}
 	}
 	parts := strings.Split(s, ",")
 	name := parts[0]
-	if name == "-" {
-		return tag{}
+	// As with encoding/json, "-" skips the field while "-," names it "-".
+	if name == "-" && len(parts) == 1 {
+		return tag{}, nil
 	}
 	id := fieldID(parent, t)
 	if name == "" {
-		name = t.Name
-	}
-	if len(parts) == 1 {
-		return tag{name: name, block: isBlock, help: help}
-	}
-	option := parts[1]
-	switch option {
-	case "optional", "omitempty":
-		return tag{name: name, block: isBlock, optional: true, help: help}
-	case "label":
-		return tag{name: name, label: true, help: help}
-	case "block":
-		return tag{name: name, block: true, optional: true, help: help}
-	case "remain":
-		return tag{name: name, remain: true, help: help}
-	default:
-		panic("invalid HCL tag option " + option + " on " + id)
+		name = fieldKey(t.Name, opt)
 	}
+	out := tag{name: name, help: help}
+	// Maps canonical option names to the option as written.
+	options := map[string]string{}
+	for _, option := range parts[1:] {
+		switch option {
+		case "":
+			continue
+		case "optional", "omitempty":
+			options["optional"] = option
+			out.optional = true
+		case "label":
+			options["label"] = option
+			out.label = true
+		case "block":
+			options["block"] = option
+			out.block = true
+			out.optional = out.optional || implicitlyOptional
+		case "remain":
+			options["remain"] = option
+			out.remain = true
+		case "comments":
+			options["comments"] = option
+			out.commentsField = true
+		case "radix=hex", "radix=octal", "radix=binary":
+			options["radix"] = option
+			out.radix = map[string]int{"radix=hex": 16, "radix=octal": 8, "radix=binary": 2}[option]
+		case "inline":
+			options["inline"] = option
+			out.inline = true
+		case "omitzero":
+			options["omitzero"] = option
+			out.omitZero = true
+		case "ident":
+			options["ident"] = option
+			out.ident = true
+		case "multiline":
+			options["multiline"] = option
+			out.multiline = true
+		case "lines":
+			options["lines"] = option
+			out.lines = true
+		default:
+			if strings.HasPrefix(option, "group=") && option != "group=" {
+				options["group"] = option
+				out.group = strings.TrimPrefix(option, "group=")
+				continue
+			}
+			if strings.HasPrefix(option, "unit=") && option != "unit=" {
+				options["unit"] = option
+				out.unit = strings.TrimPrefix(option, "unit=")
+				continue
+			}
+			if strings.HasPrefix(option, "aliases=") && option != "aliases=" {
+				options["aliases"] = option
+				out.aliases = strings.Split(strings.TrimPrefix(option, "aliases="), ";")
+				continue
+			}
+			if strings.HasPrefix(option, "convert=") {
+				options["convert"] = option
+				out.convert = strings.TrimPrefix(option, "convert=")
+				if _, ok := converterRegistry[out.convert]; !ok {
+					return tag{}, fmt.Errorf("unknown converter %q on %s", out.convert, id)
+				}
+				continue
+			}
+			// Other encoding/json options such as "string" are ignored.
+			if fromJSON {
+				continue
+			}
+			if suggestion := suggestTagOption(option); suggestion != "" {
+				return tag{}, fmt.Errorf("invalid HCL tag option %q on %s, did you mean %q?", option, id, suggestion)
+			}
+			return tag{}, fmt.Errorf("invalid HCL tag option %q on %s", option, id)
+		}
+	}
+	for _, conflict := range tagConflicts {
+		a, haveA := options[conflict[0]]
+		b, haveB := options[conflict[1]]
+		if haveA && haveB {
+			return tag{}, fmt.Errorf("conflicting HCL tag options %q and %q on %s", a, b, id)
+		}
+	}
+	if out.multiline || out.ident {
+		if ft := t.Type; ft.Kind() != reflect.String && (ft.Kind() != reflect.Ptr || ft.Elem().Kind() != reflect.String) {
+			return tag{}, fmt.Errorf("%s field %s must be a string but is %s", options["multiline"]+options["ident"], id, t.Type)
+		}
+	}
+	if out.lines && (t.Type.Kind() != reflect.Slice || t.Type.Elem().Kind() != reflect.String) {
+		return tag{}, fmt.Errorf("lines field %s must be a string slice but is %s", id, t.Type)
+	}
+	if out.inline && (t.Type.Kind() != reflect.Map || t.Type.Key().Kind() != reflect.String) {
+		return tag{}, fmt.Errorf("inline field %s must be a map with string keys but is %s", id, t.Type)
+	}
+	if out.unit != "" {
+		et := t.Type
+		for et.Kind() == reflect.Ptr || et.Kind() == reflect.Slice || et.Kind() == reflect.Array {
+			et = et.Elem()
+		}
+		if !isNumberKind(et.Kind()) {
+			return tag{}, fmt.Errorf("%s field %s must be a number but is %s", options["unit"], id, t.Type)
+		}
+	}
+	if out.commentsField {
+		if t.Type != commentsType {
+			return tag{}, fmt.Errorf("comments field %s must be of type []string but is %s", id, t.Type)
+		}
+		out.name = name
+		if parts[0] == "" {
+			out.name = ""
+		}
+		return out, nil
+	}
+	if !out.label && !out.remain {
+		out.block = out.block || isBlock
+	}
+	return out, nil
+}
+
+// Valid tag options, used to suggest corrections for misspelled options. Those ending in "=" take a value.
+var tagOptions = []string{
+	"optional", "omitempty", "label", "block", "remain", "comments", "radix=hex", "radix=octal", "radix=binary",
+	"inline", "omitzero", "ident", "multiline", "lines", "group=", "unit=", "convert=", "aliases=",
+}
+
+// suggestTagOption returns the valid tag option closest to a misspelled option, or "" if none is close.
+func suggestTagOption(option string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range tagOptions {
+		misspelled, suggestion := strings.ToLower(option), candidate
+		if strings.HasSuffix(candidate, "=") {
+			// Compare only the option name, keeping the value as written.
+			i := strings.Index(option, "=")
+			if i == -1 {
+				continue
+			}
+			misspelled, suggestion = strings.ToLower(option[:i+1]), candidate+option[i+1:]
+		}
+		distance := editDistance(misspelled, candidate)
+		if distance < bestDistance && distance*2 < len(candidate) {
+			best, bestDistance = suggestion, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = cur[j-1] + 1
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// Pairs of mutually exclusive tag options.
+var tagConflicts = [][2]string{
+	{"label", "block"},
+	{"label", "optional"},
+	{"label", "remain"},
+	{"remain", "block"},
+	{"remain", "optional"},
+	{"label", "radix"},
+	{"block", "radix"},
+	{"remain", "radix"},
+	{"comments", "label"},
+	{"comments", "block"},
+	{"comments", "remain"},
+	{"comments", "optional"},
+	{"comments", "radix"},
+	{"multiline", "label"},
+	{"multiline", "block"},
+	{"multiline", "remain"},
+	{"multiline", "comments"},
+	{"multiline", "radix"},
+	{"group", "label"},
+	{"group", "block"},
+	{"group", "remain"},
+	{"group", "comments"},
+	{"ident", "label"},
+	{"ident", "block"},
+	{"ident", "remain"},
+	{"ident", "comments"},
+	{"ident", "radix"},
+	{"ident", "multiline"},
+	{"omitzero", "label"},
+	{"omitzero", "remain"},
+	{"omitzero", "comments"},
+	{"unit", "label"},
+	{"unit", "block"},
+	{"unit", "remain"},
+	{"unit", "comments"},
+	{"unit", "radix"},
+	{"unit", "multiline"},
+	{"unit", "ident"},
+	{"inline", "label"},
+	{"inline", "block"},
+	{"inline", "remain"},
+	{"inline", "comments"},
+	{"inline", "radix"},
+	{"inline", "multiline"},
+	{"inline", "ident"},
+	{"inline", "unit"},
+	{"lines", "label"},
+	{"lines", "block"},
+	{"lines", "remain"},
+	{"lines", "comments"},
+	{"lines", "radix"},
+	{"lines", "multiline"},
+	{"lines", "ident"},
+	{"lines", "unit"},
+	{"lines", "inline"},
+	{"aliases", "label"},
+	{"aliases", "remain"},
+	{"aliases", "comments"},
+	{"aliases", "inline"},
+	{"convert", "label"},
+	{"convert", "block"},
+	{"convert", "remain"},
+	{"convert", "comments"},
+	{"convert", "inline"},
 }
 
 func implements(v reflect.Value, iface reflect.Type) (reflect.Value, bool) {
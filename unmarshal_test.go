@@ -2,9 +2,11 @@ package hcl
 
 import (
 	"fmt"
+	"math/big"
 	"net"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -523,3 +525,1057 @@ f {
 	require.NoError(t, err)
 	require.Equal(t, "f {\n  g = \"str\"\n}\n", string(data))
 }
+
+func TestPointerToPointer(t *testing.T) {
+	type config struct {
+		A **int `hcl:"a,optional"`
+		B **int `hcl:"b,optional"`
+		C **int `hcl:"c"`
+	}
+	n := 3
+	p := &n
+	var nilp *int
+	data, err := Marshal(&config{A: &p, B: &nilp, C: &nilp})
+	require.NoError(t, err)
+	require.Equal(t, "a = 3\nc = null\n", string(data))
+
+	out := &config{}
+	err = Unmarshal([]byte("a = 5\nc = null\n"), out)
+	require.NoError(t, err)
+	require.NotNil(t, out.A)
+	require.Equal(t, 5, **out.A)
+	require.Nil(t, out.B)
+	require.Nil(t, out.C)
+}
+
+func TestParseStructTags(t *testing.T) {
+	type block struct {
+		Name string `hcl:"name,label"`
+	}
+	type embedded struct {
+		Embedded string `hcl:"embedded"`
+	}
+	type conf struct {
+		embedded
+		Str     string   `hcl:"str" help:"A string."`
+		Num     int      `hcl:"num,optional"`
+		Block   block    `hcl:"block,block"`
+		Remain  []*Entry `hcl:",remain"`
+		Skipped string   `hcl:"-"`
+	}
+	tags, err := ParseStructTags(&conf{})
+	require.NoError(t, err)
+	require.Equal(t, []FieldTag{
+		{Field: "Embedded", Name: "embedded"},
+		{Field: "Str", Name: "str", Help: "A string."},
+		{Field: "Num", Name: "num", Optional: true},
+		{Field: "Block", Name: "block", Block: true, Optional: true},
+		{Field: "Remain", Name: "Remain", Remain: true},
+	}, tags)
+
+	type invalidTag struct {
+		Str string `hcl:"str,invalid"`
+	}
+	_, err = ParseStructTags(&invalidTag{})
+	require.EqualError(t, err, `invalid HCL tag option "invalid" on github.com/alecthomas/hcl.invalidTag.Str`)
+
+	_, err = ParseStructTags("str")
+	require.EqualError(t, err, "expected a struct or a pointer to a struct, not string")
+}
+
+func TestUnmarshalDecodeHook(t *testing.T) {
+	sizes := map[string]int{"KB": 1 << 10, "MB": 1 << 20}
+	sizeHook := func(from, to reflect.Type, data *Value) (interface{}, error) {
+		if from.Kind() != reflect.String || to.Kind() != reflect.Int {
+			return nil, nil
+		}
+		s := *data.Str
+		n, err := strconv.Atoi(s[:len(s)-2])
+		if err != nil {
+			return nil, err
+		}
+		return n * sizes[s[len(s)-2:]], nil
+	}
+	upperHook := func(from, to reflect.Type, data *Value) (interface{}, error) {
+		if data.Str == nil {
+			return nil, nil
+		}
+		s := strings.ToUpper(*data.Str)
+		return &Value{Pos: data.Pos, Str: &s}, nil
+	}
+	type conf struct {
+		Size  int   `hcl:"size"`
+		Sizes []int `hcl:"sizes"`
+		Count int   `hcl:"count"`
+	}
+	actual := &conf{}
+	err := Unmarshal([]byte(`
+size = "10mb"
+sizes = ["1kb", "2KB"]
+count = 3
+`), actual, WithDecodeHook(upperHook), WithDecodeHook(sizeHook))
+	require.NoError(t, err)
+	require.Equal(t, &conf{Size: 10 << 20, Sizes: []int{1 << 10, 2 << 10}, Count: 3}, actual)
+
+	err = Unmarshal([]byte(`
+size = "xMB"
+sizes = []
+count = 3
+`), actual, WithDecodeHook(sizeHook))
+	require.EqualError(t, err, `2:8: attribute "size": invalid value: strconv.Atoi: parsing "x": invalid syntax`)
+}
+
+func TestUnmarshalRequireAllNonOptional(t *testing.T) {
+	type block struct {
+		Attr string `hcl:"attr"`
+	}
+	type conf struct {
+		Name     string
+		Block    block  `hcl:"block,block"`
+		Pointer  *block `hcl:"pointer,block"`
+		Optional string `hcl:"optional,optional"`
+	}
+	err := Unmarshal([]byte(`Name = "name"`), &conf{})
+	require.NoError(t, err)
+	err = Unmarshal([]byte(`Name = "name"`), &conf{}, RequireAllNonOptional())
+	require.EqualError(t, err, `missing required block "block"`)
+	err = Unmarshal([]byte(`block { attr = "attr" }`), &conf{}, RequireAllNonOptional())
+	require.EqualError(t, err, `missing required attribute "Name"`)
+	actual := &conf{}
+	err = Unmarshal([]byte(`
+Name = "name"
+block { attr = "attr" }
+`), actual, RequireAllNonOptional())
+	require.NoError(t, err)
+	require.Equal(t, &conf{Name: "name", Block: block{Attr: "attr"}}, actual)
+}
+
+func TestUnmarshalTimeLayouts(t *testing.T) {
+	type conf struct {
+		Created time.Time `hcl:"created"`
+		Expires time.Time `hcl:"expires"`
+	}
+	src := []byte(`
+created = "2020-01-02T15:04:05Z"
+expires = "2021-03-04"
+`)
+	err := Unmarshal(src, &conf{})
+	require.EqualError(t, err, `3:11: attribute "expires": invalid time: parsing time "2021-03-04" as "2006-01-02T15:04:05Z07:00": cannot parse "" as "T"`)
+	actual := &conf{}
+	err = Unmarshal(src, actual, TimeLayouts([]string{time.RFC3339, "2006-01-02"}))
+	require.NoError(t, err)
+	require.Equal(t, &conf{
+		Created: time.Date(2020, 1, 2, 15, 4, 5, 0, time.UTC),
+		Expires: time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+	}, actual)
+}
+
+func TestParseTagConflicts(t *testing.T) {
+	type labelBlock struct {
+		Name string `hcl:"name,label,block"`
+	}
+	type labelOptional struct {
+		Name string `hcl:"name,omitempty,label"`
+	}
+	type remainBlock struct {
+		Remain []*Entry `hcl:",remain,block"`
+	}
+	type blockOptional struct {
+		Block struct{} `hcl:"block,block,optional"`
+	}
+	tests := []struct {
+		name string
+		src  interface{}
+		fail string
+	}{
+		{name: "LabelBlock", src: &labelBlock{},
+			fail: `conflicting HCL tag options "label" and "block" on github.com/alecthomas/hcl.labelBlock.Name`},
+		{name: "LabelOptional", src: &labelOptional{},
+			fail: `conflicting HCL tag options "label" and "omitempty" on github.com/alecthomas/hcl.labelOptional.Name`},
+		{name: "RemainBlock", src: &remainBlock{},
+			fail: `conflicting HCL tag options "remain" and "block" on github.com/alecthomas/hcl.remainBlock.Remain`},
+		{name: "BlockOptional", src: &blockOptional{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Marshal(test.src)
+			if test.fail != "" {
+				require.EqualError(t, err, test.fail)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalNilVersusEmpty(t *testing.T) {
+	type conf struct {
+		List []string         `hcl:"list,optional"`
+		Map  map[string]int   `hcl:"map,optional"`
+		Ptr  *string          `hcl:"ptr,optional"`
+		Nest []map[string]int `hcl:"nest,optional"`
+	}
+	actual := &conf{}
+	err := Unmarshal([]byte(`
+list = []
+map = {}
+nest = [{}, null]
+`), actual)
+	require.NoError(t, err)
+	require.NotNil(t, actual.List)
+	require.Empty(t, actual.List)
+	require.NotNil(t, actual.Map)
+	require.Empty(t, actual.Map)
+	require.NotNil(t, actual.Nest[0])
+	require.Nil(t, actual.Nest[1])
+
+	actual = &conf{}
+	err = Unmarshal([]byte(``), actual)
+	require.NoError(t, err)
+	require.Nil(t, actual.List)
+	require.Nil(t, actual.Map)
+
+	actual = &conf{List: []string{"a"}, Ptr: strp("ptr")}
+	err = Unmarshal([]byte(`
+list = null
+map = null
+ptr = null
+`), actual)
+	require.NoError(t, err)
+	require.Nil(t, actual.List)
+	require.Nil(t, actual.Map)
+	require.Nil(t, actual.Ptr)
+}
+
+func TestUnmarshalPointerBlockAllocation(t *testing.T) {
+	type nested struct {
+		Attr string `hcl:"attr"`
+	}
+	type conf struct {
+		Name   string  `hcl:"name"`
+		Nested *nested `hcl:"nested,block"`
+	}
+	present := &conf{}
+	err := Unmarshal([]byte(`
+name = "present"
+nested {
+  attr = "value"
+}
+`), present)
+	require.NoError(t, err)
+	require.Equal(t, &conf{Name: "present", Nested: &nested{Attr: "value"}}, present)
+
+	absent := &conf{}
+	err = Unmarshal([]byte(`name = "absent"`), absent)
+	require.NoError(t, err)
+	require.Nil(t, absent.Nested)
+
+	data, err := Marshal(absent)
+	require.NoError(t, err)
+	require.Equal(t, "name = \"absent\"\n", string(data))
+}
+
+func TestUnmarshalBlockAsMap(t *testing.T) {
+	type config struct {
+		Name   string                 `hcl:"name"`
+		Labels map[string]string      `hcl:"labels,block"`
+		Extra  map[string]interface{} `hcl:"extra,block,optional"`
+	}
+	out := &config{}
+	err := Unmarshal([]byte(`
+name = "web"
+labels {
+  tier = "frontend"
+  "app.kubernetes.io/name" = "web"
+}
+`), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{
+		Name:   "web",
+		Labels: map[string]string{"tier": "frontend", "app.kubernetes.io/name": "web"},
+	}, out)
+
+	err = Unmarshal([]byte(`
+name = "web"
+labels {
+  nested {}
+}
+`), &config{})
+	require.EqualError(t, err, `4:3: block "labels" decoded as a map cannot contain block "nested"`)
+
+	err = Unmarshal([]byte(`
+name = "web"
+labels "x" {}
+`), &config{})
+	require.EqualError(t, err, `3:1: block "labels" decoded as a map cannot have labels`)
+}
+
+func TestUnmarshalEmbeddedPointer(t *testing.T) {
+	type Base struct {
+		ID   string   `hcl:"id"`
+		Tags []string `hcl:"tags,optional"`
+	}
+	type Meta struct {
+		*Base
+	}
+	type config struct {
+		*Meta
+		Name string `hcl:"name"`
+	}
+	out := &config{}
+	err := Unmarshal([]byte(`
+name = "web"
+id = "abc"
+`), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{Meta: &Meta{Base: &Base{ID: "abc"}}, Name: "web"}, out)
+
+	out = &config{}
+	err = Unmarshal([]byte(`name = "web"`), out)
+	require.NoError(t, err)
+	require.Nil(t, out.Meta)
+
+	data, err := Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, "name = \"web\"\n", string(data))
+
+	type Other struct {
+		ID string `hcl:"id"`
+	}
+	type ambiguous struct {
+		*Base
+		*Other
+		Name string `hcl:"name"`
+	}
+	err = Unmarshal([]byte(`name = "web"`), &ambiguous{})
+	require.NoError(t, err)
+	err = Unmarshal([]byte(`
+name = "web"
+id = "abc"
+`), &ambiguous{})
+	require.EqualError(t, err, `3:1: ambiguous field "id" in hcl.ambiguous`)
+}
+
+type unexportedEmbedded struct {
+	Host   string `hcl:"host"`
+	secret string
+}
+
+type unexportedCount int
+
+func TestUnexportedEmbeddedStruct(t *testing.T) {
+	type config struct {
+		unexportedEmbedded
+		unexportedCount
+		Name   string `hcl:"name"`
+		hidden string
+	}
+	out := &config{}
+	err := Unmarshal([]byte(`
+host = "localhost"
+name = "web"
+`), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{unexportedEmbedded: unexportedEmbedded{Host: "localhost"}, Name: "web"}, out)
+
+	data, err := Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, "host = \"localhost\"\nname = \"web\"\n", string(data))
+
+	err = Unmarshal([]byte(`
+host = "localhost"
+name = "web"
+hidden = "x"
+`), &config{})
+	require.EqualError(t, err, `4:1: found extra fields "hidden"`)
+}
+
+func TestUnmarshalNumberLiterals(t *testing.T) {
+	type config struct {
+		Hex      int     `hcl:"hex"`
+		Octal    uint8   `hcl:"octal"`
+		Binary   int     `hcl:"binary"`
+		Negative int     `hcl:"negative"`
+		NegHex   int64   `hcl:"neg_hex"`
+		Float    float64 `hcl:"float"`
+	}
+	out := &config{}
+	err := Unmarshal([]byte(`
+hex = 0xFF
+octal = 0o17
+binary = 0b101
+negative = -42
+neg_hex = -0x10
+float = -1.5e3
+`), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{Hex: 255, Octal: 15, Binary: 5, Negative: -42, NegHex: -16, Float: -1500}, out)
+}
+
+// binaryID only implements the encoding.Binary(Un)Marshaler interfaces.
+type binaryID [4]byte
+
+func (b binaryID) MarshalBinary() ([]byte, error) { return b[:], nil }
+
+func (b *binaryID) UnmarshalBinary(data []byte) error {
+	if len(data) != len(b) {
+		return fmt.Errorf("expected %d bytes but got %d", len(b), len(data))
+	}
+	copy(b[:], data)
+	return nil
+}
+
+func TestBinaryMarshaler(t *testing.T) {
+	type config struct {
+		ID  binaryID   `hcl:"id"`
+		IDs []binaryID `hcl:"ids"`
+	}
+	in := &config{ID: binaryID{1, 2, 3, 4}, IDs: []binaryID{{0xff, 0, 0, 0xff}}}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, "id = \"AQIDBA==\"\nids = [\"/wAA/w==\"]\n", string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	err = Unmarshal([]byte(`id = "AQI="`+"\nids = []"), out)
+	require.EqualError(t, err, "1:6: attribute \"id\": invalid value: expected 4 bytes but got 2")
+}
+
+func TestUnmarshalComments(t *testing.T) {
+	type service struct {
+		Comments     []string `hcl:",comments"`
+		Name         string   `hcl:"name,label"`
+		Port         int      `hcl:"port"`
+		PortComments []string `hcl:"port,comments"`
+	}
+	type config struct {
+		Comments []string  `hcl:",comments"`
+		Services []service `hcl:"service,block"`
+	}
+	src := `// The web service.
+// Serves HTTP.
+service "web" {
+  // Port to listen on.
+  port = 80
+}
+
+service "api" {
+  port = 8080
+}
+`
+	out := &config{}
+	err := Unmarshal([]byte(src), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{
+		Services: []service{
+			{
+				Comments:     []string{"The web service.", "Serves HTTP."},
+				Name:         "web",
+				Port:         80,
+				PortComments: []string{"Port to listen on."},
+			},
+			{Name: "api", Port: 8080},
+		},
+	}, out)
+
+	data, err := Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, src, string(data))
+
+	type invalid struct {
+		Comments string `hcl:",comments"`
+	}
+	err = Unmarshal([]byte(``), &invalid{})
+	require.EqualError(t, err, "comments field github.com/alecthomas/hcl.invalid.Comments must be of type []string but is string")
+}
+
+func TestUnmarshalHeredoc(t *testing.T) {
+	type config struct {
+		Flush    string `hcl:"flush"`
+		Indented string `hcl:"indented"`
+	}
+	out := &config{}
+	err := Unmarshal([]byte(`
+flush = <<EOF
+  keep
+    this indentation
+EOF
+indented = <<-EOF
+    strip
+      common indentation
+
+    but not blank lines
+    EOF
+`), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{
+		Flush:    "  keep\n    this indentation",
+		Indented: "strip\n  common indentation\n\nbut not blank lines",
+	}, out)
+}
+
+func TestAccumulateRepeatedAttributes(t *testing.T) {
+	type config struct {
+		Tags  []string `hcl:"tag"`
+		Ports []int    `hcl:"port"`
+	}
+	src := `
+tag = "a"
+port = 80
+tag = "b"
+tag = ["c", "d"]
+`
+	out := &config{}
+	err := Unmarshal([]byte(src), out, AccumulateRepeatedAttributes())
+	require.NoError(t, err)
+	require.Equal(t, &config{Tags: []string{"a", "b", "c", "d"}, Ports: []int{80}}, out)
+
+	err = Unmarshal([]byte(src), &config{})
+	require.EqualError(t, err, `2:1: duplicate field "tag" at 4:1`)
+}
+
+type interfaceDefaultPlugin interface {
+	PluginName() string
+}
+
+type interfaceDefaultExec struct {
+	Command string `hcl:"command"`
+}
+
+func (e *interfaceDefaultExec) PluginName() string { return "exec" }
+
+func TestUnmarshalInterfaceDefault(t *testing.T) {
+	type config struct {
+		Plugin interfaceDefaultPlugin `hcl:"plugin,block"`
+		Extra  interface{}            `hcl:"extra,block"`
+		Value  interface{}            `hcl:"value"`
+	}
+	src := `
+plugin {
+  command = "ls"
+}
+
+extra {
+  size = 1
+  tags = ["a"]
+}
+
+value = true
+`
+	pluginType := reflect.TypeOf((*interfaceDefaultPlugin)(nil)).Elem()
+	out := &config{}
+	err := Unmarshal([]byte(src), out, WithInterfaceDefault(pluginType, (*interfaceDefaultExec)(nil)))
+	require.NoError(t, err)
+	require.Equal(t, &config{
+		Plugin: &interfaceDefaultExec{Command: "ls"},
+		Extra:  map[string]interface{}{"size": 1.0, "tags": []interface{}{"a"}},
+		Value:  true,
+	}, out)
+
+	err = Unmarshal([]byte(src), &config{})
+	require.EqualError(t, err, "2:1: no default type for interface hcl.interfaceDefaultPlugin, see WithInterfaceDefault")
+}
+
+func TestUnmarshalAllowColonAssignment(t *testing.T) {
+	type config struct {
+		Port   int               `hcl:"port"`
+		Labels map[string]string `hcl:"labels"`
+		Server struct {
+			Host string `hcl:"host"`
+		} `hcl:"server,block"`
+	}
+	src := `
+port: 8080
+labels: {env: "prod", "team" = "web"}
+server {
+  host = "localhost"
+}
+`
+	out := &config{}
+	err := Unmarshal([]byte(src), out, AllowColonAssignment())
+	require.NoError(t, err)
+	require.Equal(t, 8080, out.Port)
+	require.Equal(t, map[string]string{"env": "prod", "team": "web"}, out.Labels)
+	require.Equal(t, "localhost", out.Server.Host)
+
+	data, err := Marshal(out)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "port = 8080\n")
+
+	err = Unmarshal([]byte(src), &config{})
+	require.Error(t, err)
+}
+
+// customUnmarshaler decodes "name" from its block label, or from an attribute string value.
+type customUnmarshaler struct {
+	Name string `hcl:"name"`
+	Via  string
+}
+
+func (c *customUnmarshaler) UnmarshalHCL(node Node) error {
+	switch node := node.(type) {
+	case *Block:
+		c.Name = node.Labels[0]
+		c.Via = "block"
+	case *Value:
+		if node.Str == nil {
+			return fmt.Errorf("expected a string but got %s", node)
+		}
+		c.Name = *node.Str
+		c.Via = "value"
+	default:
+		return fmt.Errorf("unexpected node %T", node)
+	}
+	return nil
+}
+
+func TestUnmarshalerPrecedence(t *testing.T) {
+	type config struct {
+		Block  *customUnmarshaler  `hcl:"block,block"`
+		Blocks []customUnmarshaler `hcl:"item,block"`
+		Attr   customUnmarshaler   `hcl:"attr"`
+	}
+	out := &config{}
+	err := Unmarshal([]byte(`
+block "a" {
+  ignored = true
+}
+item "b" {}
+item "c" {}
+attr = "d"
+`), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{
+		Block:  &customUnmarshaler{Name: "a", Via: "block"},
+		Blocks: []customUnmarshaler{{Name: "b", Via: "block"}, {Name: "c", Via: "block"}},
+		Attr:   customUnmarshaler{Name: "d", Via: "value"},
+	}, out)
+
+	err = Unmarshal([]byte(`
+item "b" {}
+attr = 1
+`), &config{})
+	require.EqualError(t, err, "3:8: attribute \"attr\": invalid value: expected a string but got 1")
+}
+
+func TestBigFloat(t *testing.T) {
+	type config struct {
+		Large     *big.Float   `hcl:"large"`
+		Separated big.Float    `hcl:"separated"`
+		Pi        *big.Float   `hcl:"pi"`
+		List      []*big.Float `hcl:"list"`
+		Unset     *big.Float   `hcl:"unset,optional"`
+	}
+	src := `
+large = 1.23456789012345e+30
+separated = 1_000
+pi = 3.14159265358979323846264338327950288
+list = [1e10, "2.5"]
+`
+	out := &config{}
+	err := Unmarshal([]byte(src), out)
+	require.NoError(t, err)
+	require.Equal(t, "1.23456789012345e+30", out.Large.Text('g', -1))
+	require.Equal(t, "1000", out.Separated.Text('g', -1))
+	require.Equal(t, "3.14159265358979323846264338327950288", out.Pi.Text('g', -1))
+	require.Equal(t, "1e+10", out.List[0].Text('g', -1))
+	require.Equal(t, "2.5", out.List[1].Text('g', -1))
+
+	data, err := Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, `large = 1.23456789012345e+30
+separated = 1000
+pi = 3.14159265358979323846264338327950288
+list = [10000000000, 2.5]
+`, string(data))
+
+	roundtrip := &config{}
+	err = Unmarshal(data, roundtrip)
+	require.NoError(t, err)
+	require.Zero(t, out.Large.Cmp(roundtrip.Large))
+	require.Zero(t, out.Pi.Cmp(roundtrip.Pi))
+
+	err = Unmarshal([]byte(`large = true`), &config{})
+	require.EqualError(t, err, "1:9: attribute \"large\": expected number, got bool true")
+}
+
+func TestUnmarshalWithWarnings(t *testing.T) {
+	type server struct {
+		Port    int `hcl:"port"`
+		Timeout int `hcl:"timeout,optional"`
+	}
+	type config struct {
+		Name   string `hcl:"name"`
+		Server server `hcl:"server,block"`
+	}
+	src := `
+// The name.
+name = "web"
+
+server {
+  // DEPRECATED: use timeout instead
+  port = 8080
+}
+`
+	warnings := []Warning{}
+	out := &config{}
+	err := Unmarshal([]byte(src), out, WithWarnings(&warnings))
+	require.NoError(t, err)
+	require.Equal(t, &config{Name: "web", Server: server{Port: 8080}}, out)
+	require.Len(t, warnings, 1)
+	require.Equal(t, "port", warnings[0].Key)
+	require.Equal(t, `6:3: "port" is deprecated: use timeout instead`, warnings[0].String())
+}
+
+func TestUnitTag(t *testing.T) {
+	type config struct {
+		Timeout  int       `hcl:"timeout,unit=s"`
+		Ratio    float64   `hcl:"ratio,unit=%"`
+		Backoffs []int     `hcl:"backoffs,unit=ms"`
+		Limit    *uint     `hcl:"limit,unit=MB,optional"`
+		Sizes    [2]uint16 `hcl:"sizes,unit=px,optional"`
+	}
+	limit := uint(512)
+	in := &config{Timeout: 30, Ratio: 12.5, Backoffs: []int{100, 250}, Limit: &limit, Sizes: [2]uint16{800, 600}}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `timeout = "30s"
+ratio = "12.5%"
+backoffs = ["100ms", "250ms"]
+limit = "512MB"
+sizes = ["800px", "600px"]
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	// Bare numbers are accepted, but mismatched units are not.
+	out = &config{}
+	err = Unmarshal([]byte("timeout = 30\nratio = \"1%\"\nbackoffs = []\n"), out)
+	require.NoError(t, err)
+	require.Equal(t, 30, out.Timeout)
+	err = Unmarshal([]byte("timeout = \"30ms\"\nratio = 1\nbackoffs = []\n"), &config{})
+	require.EqualError(t, err, `1:11: expected a number with unit "s" but got "30ms"`)
+	err = Unmarshal([]byte("timeout = \"s\"\nratio = 1\nbackoffs = []\n"), &config{})
+	require.EqualError(t, err, `1:11: expected a number with unit "s" but got "s"`)
+
+	type invalid struct {
+		Name string `hcl:"name,unit=s"`
+	}
+	_, err = Marshal(&invalid{})
+	require.EqualError(t, err, `unit=s field github.com/alecthomas/hcl.invalid.Name must be a number but is string`)
+}
+
+func TestUnmarshalResetsSlices(t *testing.T) {
+	type item struct {
+		Name  string `hcl:"name,label"`
+		Value int    `hcl:"value,optional"`
+	}
+	type config struct {
+		Tags  []string `hcl:"tags"`
+		Items []*item  `hcl:"item,block"`
+	}
+	first := []byte(`
+tags = ["a", "b", "c"]
+item "x" {
+  value = 1
+}
+item "y" {
+  value = 2
+}
+`)
+	second := []byte(`
+tags = ["d"]
+item "z" {}
+`)
+	out := &config{}
+	require.NoError(t, Unmarshal(first, out))
+	require.NoError(t, Unmarshal(second, out))
+	require.Equal(t, &config{Tags: []string{"d"}, Items: []*item{{Name: "z"}}}, out)
+
+	// With ReuseSlices, existing elements are reused in place.
+	out = &config{}
+	require.NoError(t, Unmarshal(first, out, ReuseSlices(true)))
+	tags, x := out.Tags, out.Items[0]
+	require.NoError(t, Unmarshal(second, out, ReuseSlices(true)))
+	require.Equal(t, &config{Tags: []string{"d"}, Items: []*item{{Name: "z", Value: 1}}}, out)
+	require.Equal(t, []string{"d", "b", "c"}, tags)
+	require.True(t, x == out.Items[0])
+}
+
+func TestInlineMap(t *testing.T) {
+	type resource struct {
+		Type   string            `hcl:"type,label"`
+		Count  int               `hcl:"count,optional"`
+		Params map[string]string `hcl:",inline"`
+	}
+	type config struct {
+		Resources []resource `hcl:"resource,block"`
+	}
+	in := &config{Resources: []resource{
+		{Type: "bucket", Count: 2, Params: map[string]string{"region": "us-east-1", "acl": "private"}},
+		{Type: "queue"},
+	}}
+	data, err := Marshal(in)
+	require.NoError(t, err)
+	require.Equal(t, `resource "bucket" {
+  count = 2
+  acl = "private"
+  region = "us-east-1"
+}
+
+resource "queue" {
+}
+`, string(data))
+	out := &config{}
+	err = Unmarshal(data, out)
+	require.NoError(t, err)
+	require.Equal(t, in, out)
+
+	err = Unmarshal([]byte(`
+resource "bucket" {
+  nested {}
+}
+`), &config{})
+	require.EqualError(t, err, `3:3: found extra fields "nested"`)
+
+	type invalid struct {
+		Params []string `hcl:",inline"`
+	}
+	err = Unmarshal([]byte(``), &invalid{})
+	require.EqualError(t, err, `inline field github.com/alecthomas/hcl.invalid.Params must be a map with string keys but is []string`)
+}
+
+func TestTypeMismatchErrors(t *testing.T) {
+	type server struct {
+		Name    string            `hcl:"name,optional"`
+		Port    int               `hcl:"port,optional"`
+		Enabled bool              `hcl:"enabled,optional"`
+		Hosts   []string          `hcl:"hosts,optional"`
+		Labels  map[string]string `hcl:"labels,optional"`
+	}
+	tests := []struct {
+		name string
+		src  string
+		fail string
+	}{
+		{name: "StringForNumber", src: `port = "abc"`, fail: `1:8: attribute "port": expected number, got string "abc"`},
+		{name: "BoolForNumber", src: "\n\nport = true", fail: `3:8: attribute "port": expected number, got bool true`},
+		{name: "NumberForString", src: `name = 1`, fail: `1:8: attribute "name": expected string, got number 1`},
+		{name: "ListForBool", src: `enabled = [true]`, fail: `1:11: attribute "enabled": expected bool, got list [true]`},
+		{name: "StringForList", src: `hosts = "a"`, fail: `1:9: attribute "hosts": expected list, got string "a"`},
+		{name: "ListForMap", src: `labels = ["a"]`, fail: `1:10: attribute "labels": expected map, got list ["a"]`},
+		{name: "ListElement", src: `hosts = ["a", 2]`, fail: `1:15: attribute "hosts": invalid list element: expected string, got number 2`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Unmarshal([]byte(test.src), &server{})
+			require.EqualError(t, err, test.fail)
+		})
+	}
+}
+
+func TestUnmarshalLabelAndAttributeWithSameName(t *testing.T) {
+	type service struct {
+		Label string `hcl:"name,label"`
+		Name  string `hcl:"name"`
+	}
+	type config struct {
+		Services []service `hcl:"service,block"`
+	}
+	src := `
+service "web" {
+  name = "Web Server"
+}
+`
+	out := &config{}
+	err := Unmarshal([]byte(src), out)
+	require.NoError(t, err)
+	require.Equal(t, &config{Services: []service{{Label: "web", Name: "Web Server"}}}, out)
+
+	data, err := Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, src[1:], string(data))
+
+	// The attribute is not consumed by the label field.
+	type labelOnly struct {
+		Name string `hcl:"name,label"`
+	}
+	err = Unmarshal([]byte(`service "web" { name = "Web Server" }`), &struct {
+		Service labelOnly `hcl:"service,block"`
+	}{})
+	require.EqualError(t, err, `1:17: found extra fields "name"`)
+}
+
+func TestUnmarshalDefaults(t *testing.T) {
+	type limits struct {
+		CPU int `hcl:"cpu" default:"1"`
+	}
+	type config struct {
+		Host   string   `hcl:"host" default:"localhost"`
+		Port   *int     `hcl:"port" default:"8080"`
+		Tags   []string `hcl:"tags" default:"[\"web\"]"`
+		Limits limits   `hcl:"limits,block"`
+	}
+	port := 8080
+	tests := []struct {
+		name     string
+		src      string
+		expected *config
+	}{
+		{name: "Absent",
+			src:      ``,
+			expected: &config{Host: "localhost", Port: &port, Tags: []string{"web"}}},
+		{name: "Null",
+			src:      "host = null\nport = null\ntags = null",
+			expected: &config{}},
+		{name: "Set",
+			src:      "host = \"example.com\"\nport = 80\ntags = []\nlimits {\n  cpu = 4\n}",
+			expected: &config{Host: "example.com", Port: func() *int { p := 80; return &p }(), Tags: []string{}, Limits: limits{CPU: 4}}},
+		{name: "BlockDefaults",
+			src:      "host = null\nport = null\ntags = null\nlimits {}",
+			expected: &config{Limits: limits{CPU: 1}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &config{}
+			err := Unmarshal([]byte(test.src), out)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, out)
+		})
+	}
+
+	type invalid struct {
+		Port int `hcl:"port" default:"{"`
+	}
+	err := Unmarshal([]byte(``), &invalid{})
+	require.Error(t, err)
+}
+
+func TestParseTagSuggestions(t *testing.T) {
+	type canonical struct {
+		Name string `hcl:"name,label"`
+	}
+	type plural struct {
+		Name string `hcl:"name,labels"`
+	}
+	type capitalised struct {
+		Name string `hcl:"name,Label"`
+	}
+	type optinal struct {
+		Port int `hcl:"port,optinal"`
+	}
+	type grup struct {
+		Host string `hcl:"host,grup=network"`
+	}
+	type unknown struct {
+		Name string `hcl:"name,bogus"`
+	}
+	tests := []struct {
+		name string
+		src  interface{}
+		fail string
+	}{
+		{name: "Canonical", src: &canonical{}},
+		{name: "Plural", src: &plural{},
+			fail: `invalid HCL tag option "labels" on github.com/alecthomas/hcl.plural.Name, did you mean "label"?`},
+		{name: "Capitalised", src: &capitalised{},
+			fail: `invalid HCL tag option "Label" on github.com/alecthomas/hcl.capitalised.Name, did you mean "label"?`},
+		{name: "Misspelled", src: &optinal{},
+			fail: `invalid HCL tag option "optinal" on github.com/alecthomas/hcl.optinal.Port, did you mean "optional"?`},
+		{name: "MisspelledWithValue", src: &grup{},
+			fail: `invalid HCL tag option "grup=network" on github.com/alecthomas/hcl.grup.Host, did you mean "group=network"?`},
+		{name: "Unknown", src: &unknown{},
+			fail: `invalid HCL tag option "bogus" on github.com/alecthomas/hcl.unknown.Name`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseStructTags(test.src)
+			if test.fail != "" {
+				require.EqualError(t, err, test.fail)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestUnmarshalAliases(t *testing.T) {
+	type limits struct {
+		CPU int `hcl:"cpu"`
+	}
+	type config struct {
+		Timeout int     `hcl:"timeout,optional,aliases=ttl;deadline"`
+		Limits  *limits `hcl:"limits,block,aliases=quota"`
+	}
+	tests := []struct {
+		name     string
+		src      string
+		expected *config
+		fail     string
+	}{
+		{name: "Canonical", src: `timeout = 1`, expected: &config{Timeout: 1}},
+		{name: "Alias", src: `ttl = 2`, expected: &config{Timeout: 2}},
+		{name: "SecondAlias", src: `deadline = 3`, expected: &config{Timeout: 3}},
+		{name: "BlockAlias", src: `quota { cpu = 4 }`, expected: &config{Limits: &limits{CPU: 4}}},
+		{name: "CanonicalAndAlias", src: "timeout = 1\nttl = 2",
+			fail: `2:1: "timeout" and "ttl" are aliases of the same field, only one may be set`},
+		{name: "TwoAliases", src: "deadline = 1\nttl = 2",
+			fail: `1:1: "ttl" and "deadline" are aliases of the same field, only one may be set`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := &config{}
+			err := Unmarshal([]byte(test.src), out)
+			if test.fail != "" {
+				require.EqualError(t, err, test.fail)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, out)
+		})
+	}
+
+	data, err := Marshal(&config{Timeout: 5})
+	require.NoError(t, err)
+	require.Equal(t, "timeout = 5\n", string(data))
+}
+
+func TestBigRat(t *testing.T) {
+	type config struct {
+		Third   *big.Rat   `hcl:"third"`
+		Quarter big.Rat    `hcl:"quarter"`
+		List    []*big.Rat `hcl:"list"`
+	}
+	src := `
+third = "1/3"
+quarter = 0.25
+list = ["2/4", 0.1, 3]
+`
+	out := &config{}
+	err := Unmarshal([]byte(src), out)
+	require.NoError(t, err)
+	require.Equal(t, "1/3", out.Third.String())
+	require.Equal(t, "1/4", out.Quarter.String())
+	require.Equal(t, []string{"1/2", "1/10", "3/1"}, []string{out.List[0].String(), out.List[1].String(), out.List[2].String()})
+
+	data, err := Marshal(out)
+	require.NoError(t, err)
+	require.Equal(t, `third = "1/3"
+quarter = "1/4"
+list = ["1/2", "1/10", "3"]
+`, string(data))
+
+	roundtrip := &config{}
+	err = Unmarshal(data, roundtrip)
+	require.NoError(t, err)
+	require.Equal(t, out, roundtrip)
+
+	err = Unmarshal([]byte(`third = "1/0"`), &config{})
+	require.EqualError(t, err, `1:9: attribute "third": invalid fraction or decimal "1/0"`)
+	err = Unmarshal([]byte(`third = true`), &config{})
+	require.EqualError(t, err, `1:9: attribute "third": expected fraction or number, got bool true`)
+}
@@ -6,15 +6,20 @@ import (
 	"unicode"
 )
 
-// StripComments recursively from an AST node.
+// StripComments recursively from an AST node, including leading and trailing comments.
 func StripComments(node Node) error {
 	return Visit(node, func(node Node, next func() error) error {
 		switch node := node.(type) {
+		case *AST:
+			node.LeadingComments = nil
+			node.TrailingComments = nil
+
 		case *Attribute:
 			node.Comments = nil
 
 		case *Block:
 			node.Comments = nil
+			node.TrailingComments = nil
 
 		case *MapEntry:
 			node.Comments = nil
@@ -23,6 +28,11 @@ func StripComments(node Node) error {
 	})
 }
 
+// StripComments recursively removes all comments from the AST.
+func (a *AST) StripComments() {
+	_ = StripComments(a)
+}
+
 // AddParentRefs recursively updates an AST's parent references.
 //
 // This is called automatically during Parse*(), but can be called on a manually constructed AST.
@@ -77,13 +87,21 @@ func addParentRefs(parent, node Node) {
 	}
 }
 
+// dedent removes the longest whitespace prefix common to all lines.
+//
+// Empty lines are ignored when determining the prefix, unless all lines are empty.
 func dedent(s string) string {
 	lines := strings.Split(s, "\n")
-	indent := whitespacePrefix(lines[0])
+	indent := ""
+	found := false
 	for _, line := range lines {
+		if line == "" {
+			continue
+		}
 		candidate := whitespacePrefix(line)
-		if len(candidate) < len(indent) {
+		if !found || len(candidate) < len(indent) {
 			indent = candidate
+			found = true
 		}
 	}
 	for i, line := range lines {
@@ -92,12 +110,24 @@ func dedent(s string) string {
 	return strings.Join(lines, "\n")
 }
 
+// whitespacePrefix returns the leading whitespace of s.
 func whitespacePrefix(s string) string {
-	indent := ""
-	for _, rn := range s {
-		if unicode.IsSpace(rn) {
-			indent += string(rn)
+	return s[:len(s)-len(strings.TrimLeftFunc(s, unicode.IsSpace))]
+}
+
+// snakeCase converts a Go identifier such as "HTTPServerID" to snake case, eg. "http_server_id".
+func snakeCase(s string) string {
+	runes := []rune(s)
+	out := strings.Builder{}
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				out.WriteRune('_')
+			}
 		}
+		out.WriteRune(unicode.ToLower(r))
 	}
-	return indent
+	return out.String()
 }
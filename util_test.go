@@ -8,7 +8,49 @@ import (
 
 func TestDedent(t *testing.T) {
 	require.Equal(t, "", dedent(""))
-	require.Equal(t, "\n  ", dedent("\n  "))
+	// Empty lines don't contribute to the common prefix.
+	require.Equal(t, "\n", dedent("\n  "))
 	require.Equal(t, "\n", dedent("  \n  "))
 	require.Equal(t, "  \n", dedent("    \n  "))
+	require.Equal(t, "a b\n\n  c", dedent("  a b\n\n    c"))
+}
+
+func TestSnakeCase(t *testing.T) {
+	require.Equal(t, "max_retries", snakeCase("MaxRetries"))
+	require.Equal(t, "http_server_id", snakeCase("HTTPServerID"))
+	require.Equal(t, "id", snakeCase("ID"))
+	require.Equal(t, "name", snakeCase("name"))
+}
+
+func TestASTStripComments(t *testing.T) {
+	ast, err := ParseString(`
+// Attribute comment.
+name = "web"
+
+/* Block comment. */
+server "main" {
+  // Nested comment.
+  port = 80
+  env = {
+    // Map entry comment.
+    "HOME": "/",
+  }
+  // Trailing block comment.
+}
+// Trailing comment.
+`)
+	require.NoError(t, err)
+	ast.LeadingComments = []string{"Header."}
+	ast.StripComments()
+	data, err := MarshalAST(ast)
+	require.NoError(t, err)
+	require.Equal(t, `name = "web"
+
+server "main" {
+  port = 80
+  env = {
+    "HOME": "/",
+  }
+}
+`, string(data))
 }